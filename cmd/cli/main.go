@@ -9,20 +9,37 @@ command that can be used to source the bundle into the environment.
 
 Usage:
   go run github.com/aws-robotics/aws-robomaker-bundle-support-library \
-		--bundle <path to bundle> \
+		--bundle <path or http(s) URL of bundle> \
 		--cache (optional) <path to cache directory (default: cache)> \
-		--prefix (optional) <prefix for source command paths (must include cache directory)>
+		--prefix (optional) <prefix for source command paths (must include cache directory)> \
+		--verbose (optional) <log the processing of the bundle to stderr>
+
+It can also create a v2 bundle out of overlay directories or tarballs:
+  go run github.com/aws-robotics/aws-robomaker-bundle-support-library create \
+		--output (optional) <path to bundle (default: bundle.tar)> \
+		--overlay-format (optional) <archive format of overlays created from directories, .tar.gz, .tar.zst, .tar.xz, .tar.bz2 or .zip (default: .tar.gz)> \
+		<overlay path> [<overlay path>...]
+
+And print the version and overlays of a bundle without extracting it:
+  go run github.com/aws-robotics/aws-robomaker-bundle-support-library inspect \
+		--cache (optional) <path to cache directory to check for extracted overlays (default: cache)> \
+		--json (optional) \
+		<path to bundle>
 */
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/stream/http"
 	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/stream/local"
 	"io/ioutil"
 	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/bundle"
 	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/store"
@@ -36,14 +53,40 @@ func main() {
 	app.Usage = "Extracts a bundle and prints the command to source the bundle into a shell environment. " +
 		"Will intelligently cache in the cache directory."
 	app.Flags = []cli.Flag{
-		cli.StringFlag{Name: "bundle", Value: "", Usage: "Path to bundle file"},
+		cli.StringFlag{Name: "bundle", Value: "", Usage: "Path or http(s) URL of the bundle file"},
 		cli.StringFlag{Name: "prefix", Value: "", Usage: "Prefix to put onto the source command"},
 		cli.StringFlag{Name: "cache", Value: "cache", Usage: "Folder to be used as the cache " +
 			"directory for extracted bundles."},
+		cli.BoolFlag{Name: "verbose", Usage: "Log the processing of the bundle to stderr"},
+	}
+
+	app.Commands = []cli.Command{
+		{
+			Name:      "create",
+			Usage:     "Creates a v2 bundle out of overlay directories or tarballs, sourced in the given order.",
+			ArgsUsage: "<overlay path> [<overlay path>...]",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "output", Value: "bundle.tar", Usage: "Path of the bundle to create"},
+				cli.StringFlag{Name: "overlay-format", Value: ".tar.gz", Usage: "Archive format of the overlays " +
+					"created from directories, .tar.gz, .tar.zst, .tar.xz, .tar.bz2 or .zip"},
+			},
+			Action: createBundle,
+		},
+		{
+			Name:      "inspect",
+			Usage:     "Prints the version and overlays of a bundle, and whether the overlays are in the cache.",
+			ArgsUsage: "<path to bundle>",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "cache", Value: "cache", Usage: "Folder used as the cache directory for extracted bundles"},
+				cli.BoolFlag{Name: "json", Usage: "Print the manifest as JSON"},
+			},
+			Action: inspectBundle,
+		},
 	}
 
 	local := local.NewStreamer()
 	stream.RegisterStreamer(local)
+	stream.RegisterStreamer(http.NewStreamer(nil))
 
 	app.Action = func(c *cli.Context) error {
 		cachePath := c.String("cache")
@@ -55,14 +98,15 @@ func main() {
 				return err
 			}
 		}
-		bundleStore := store.NewSimpleStore(cachePath)
+		logger := newLogger(c.Bool("verbose"))
+		bundleStore := store.NewSimpleStore(cachePath, store.WithLogger(logger))
 
 		bundlePath := c.String("bundle")
 		if bundlePath == "" {
 			fmt.Println("Bundle path cannot be empty.")
 			return errors.New("bundle path cannot be empty")
 		}
-		absBundlePath, err := filepath.Abs(bundlePath)
+		absBundlePath, err := bundleURL(bundlePath)
 		if err != nil {
 			fmt.Printf("Bundle path is invalid: %s", bundlePath)
 			log.Fatal(err)
@@ -70,25 +114,14 @@ func main() {
 		}
 		prefixPath := c.String("prefix")
 
-		files, err := ioutil.ReadDir(cachePath)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		var keys []string
-		for _, file := range files {
-			if file.IsDir() {
-				keys = append(keys, file.Name())
-			}
-		}
-
-		err = bundleStore.Load(keys)
+		err = loadCache(bundleStore)
 		if err != nil {
 			log.Fatal(err)
 			return err
 		}
 
 		bundleProvider := bundle.NewProvider(bundleStore)
+		bundleProvider.SetLogger(logger)
 		b, err := bundleProvider.GetBundle(absBundlePath)
 		if err != nil {
 			log.Fatal(err)
@@ -102,3 +135,111 @@ func main() {
 	}
 	app.Run(os.Args)
 }
+
+// newLogger returns a logger writing to stderr when verbose is set, so that stdout
+// only has the output of the command, and nil otherwise
+func newLogger(verbose bool) *slog.Logger {
+	if !verbose {
+		return nil
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+// bundleURL makes a local bundle path absolute, URLs are streamed as they are
+func bundleURL(bundlePath string) (string, error) {
+	if strings.Contains(bundlePath, "://") {
+		return bundlePath, nil
+	}
+	return filepath.Abs(bundlePath)
+}
+
+func createBundle(c *cli.Context) error {
+	overlayPaths := []string(c.Args())
+	if len(overlayPaths) == 0 {
+		fmt.Println("At least one overlay path is required.")
+		return errors.New("overlay paths cannot be empty")
+	}
+
+	outputPath := c.String("output")
+	writer := bundle.NewWriter(bundle.WithOverlayFileSuffix(c.String("overlay-format")))
+	if err := writer.Make(outputPath, overlayPaths); err != nil {
+		log.Fatal(err)
+		return err
+	}
+	fmt.Printf("Created bundle: %s\n", outputPath)
+	return nil
+}
+
+func inspectBundle(c *cli.Context) error {
+	bundlePath := c.Args().First()
+	if bundlePath == "" {
+		fmt.Println("Bundle path cannot be empty.")
+		return errors.New("bundle path cannot be empty")
+	}
+	absBundlePath, err := bundleURL(bundlePath)
+	if err != nil {
+		fmt.Printf("Bundle path is invalid: %s", bundlePath)
+		log.Fatal(err)
+		return err
+	}
+
+	// overlays can only be in the cache if it exists, don't create it
+	var manifest *bundle.Manifest
+	cachePath := c.String("cache")
+	if _, statErr := os.Stat(cachePath); statErr == nil {
+		// --verbose is a flag of the app, not of the command
+		logger := newLogger(c.GlobalBool("verbose"))
+		bundleStore := store.NewSimpleStore(cachePath, store.WithLogger(logger))
+		if err = loadCache(bundleStore); err != nil {
+			log.Fatal(err)
+			return err
+		}
+		bundleProvider := bundle.NewProvider(bundleStore)
+		bundleProvider.SetLogger(logger)
+		manifest, err = bundleProvider.Inspect(absBundlePath)
+	} else {
+		manifest, err = bundle.Inspect(absBundlePath)
+	}
+	if err != nil {
+		log.Fatal(err)
+		return err
+	}
+
+	if c.Bool("json") {
+		manifestJSON, jsonErr := json.MarshalIndent(manifest, "", "  ")
+		if jsonErr != nil {
+			log.Fatal(jsonErr)
+			return jsonErr
+		}
+		fmt.Println(string(manifestJSON))
+		return nil
+	}
+
+	fmt.Printf("Version: %s\n", manifest.Version)
+	fmt.Printf("Content ID: %s\n", manifest.ContentID)
+	fmt.Printf("Size: %d bytes\n", manifest.ContentLength)
+	fmt.Printf("Overlays: %d\n", len(manifest.Overlays))
+	for _, overlay := range manifest.Overlays {
+		fmt.Printf("  %s\n", overlay.Name)
+		fmt.Printf("    sha256: %s\n", overlay.Sha256)
+		fmt.Printf("    offset: %d, size: %d bytes, cached: %t\n", overlay.Offset, overlay.Size, overlay.Cached)
+	}
+	return nil
+}
+
+// loadCache loads the items found in the root path of bundleStore
+func loadCache(bundleStore bundle.Cache) error {
+	files, err := ioutil.ReadDir(bundleStore.RootPath())
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+	for _, file := range files {
+		if file.IsDir() {
+			keys = append(keys, file.Name())
+		}
+	}
+
+	return bundleStore.Load(keys)
+}
@@ -3,11 +3,14 @@ package archiver
 import (
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/logging"
 )
 
 // Archiver represent a archive format
@@ -22,15 +25,75 @@ type Archiver interface {
 	Write(output io.Writer, sources []string) error
 	// Read reads an archive from a Reader.
 	Read(input io.Reader, destination string) error
+	// ReadWithOptions reads an archive from a Reader, writing its files through fileSystem.
+	ReadWithOptions(input io.Reader, destination string, fileSystem fs.FileSystem, options ExtractOptions) error
+}
+
+// ExtractOptions selects the file metadata kept from an archive when it is read,
+// and limits how much of it is extracted. The zero value only keeps file modes and has no limits.
+type ExtractOptions struct {
+	// PreserveModTimes sets the modification time of files and directories to the one in the archive
+	PreserveModTimes bool
+	// PreserveOwnership sets the owner and group of files to the ones in the archive, when running as root
+	PreserveOwnership bool
+	// PreserveXattrs sets the extended attributes of files stored in PAX records, such as file capabilities
+	PreserveXattrs bool
+
+	// MaxBytes is the most bytes of file contents extracted from an archive, 0 for no limit
+	MaxBytes int64
+	// MaxEntries is the most files, directories and links extracted from an archive, 0 for no limit
+	MaxEntries int
+	// MaxPathDepth is the most elements in the path of an entry, 0 for no limit
+	MaxPathDepth int
+}
+
+// Violation is a rule of extraction broken by an entry of an archive
+type Violation string
+
+const (
+	// ViolationPathEscape is an entry whose path is outside of the destination
+	ViolationPathEscape Violation = "path outside of the destination"
+	// ViolationLinkEscape is a symbolic or hard link whose target is outside of the destination
+	ViolationLinkEscape Violation = "link target outside of the destination"
+	// ViolationLinkTargetLength is a symbolic link whose target is longer than a path can be
+	ViolationLinkTargetLength Violation = "link target too long"
+	// ViolationSymlinkPath is an entry written through a symbolic link extracted before it
+	ViolationSymlinkPath Violation = "path through a symbolic link"
+	// ViolationMaxBytes is an entry past the MaxBytes limit
+	ViolationMaxBytes Violation = "more bytes than the limit"
+	// ViolationMaxEntries is an entry past the MaxEntries limit
+	ViolationMaxEntries Violation = "more entries than the limit"
+	// ViolationMaxPathDepth is an entry deeper than the MaxPathDepth limit
+	ViolationMaxPathDepth Violation = "path deeper than the limit"
+)
+
+// ViolationError is returned when an entry of an archive breaks a rule of extraction.
+// The entries before it are left extracted.
+type ViolationError struct {
+	// Name of the entry in the archive
+	Name      string
+	Violation Violation
+}
+
+func (e *ViolationError) Error() string {
+	return fmt.Sprintf("%s: illegal file path: %s", e.Name, e.Violation)
 }
 
 // SupportedFormats contains all supported archive formats
 var SupportedFormats = map[string]Archiver{}
 
+// logger records the formats which are registered more than once
+var logger = logging.Discard()
+
+// SetLogger sets the logger of the package, it is silent by default
+func SetLogger(l *slog.Logger) {
+	logger = logging.OrDiscard(l)
+}
+
 // RegisterFormat adds a supported archive format
 func RegisterFormat(name string, format Archiver) {
 	if _, ok := SupportedFormats[name]; ok {
-		log.Printf("Format %s already exists, skip!\n", name)
+		logger.Warn("archive format already exists, skipping it", "format", name)
 		return
 	}
 	SupportedFormats[name] = format
@@ -47,78 +110,83 @@ func MatchingFormat(fpath string) Archiver {
 	return nil
 }
 
-func writeNewFile(fpath string, in io.Reader, fm os.FileMode) error {
-	err := os.MkdirAll(filepath.Dir(fpath), 0755)
+// isRoot is true when the process can give files to other users
+func isRoot() bool {
+	return os.Geteuid() == 0
+}
+
+func writeNewFile(fileSystem fs.FileSystem, fpath string, in io.Reader, fm os.FileMode) error {
+	err := fileSystem.MkdirAll(filepath.Dir(fpath), 0755)
 	if err != nil {
-		return fmt.Errorf("%s: making directory for file: %v", fpath, err)
+		return fmt.Errorf("%s: making directory for file: %w", fpath, err)
 	}
 
-	out, err := os.Create(fpath)
+	out, err := fileSystem.Create(fpath)
 	if err != nil {
-		return fmt.Errorf("%s: creating new file: %v", fpath, err)
+		return fmt.Errorf("%s: creating new file: %w", fpath, err)
 	}
 	defer out.Close()
 
-	err = out.Chmod(fm)
+	err = fileSystem.Chmod(fpath, fs.FileMode(fm))
 	if err != nil && runtime.GOOS != "windows" {
-		return fmt.Errorf("%s: changing file mode: %v", fpath, err)
+		return fmt.Errorf("%s: changing file mode: %w", fpath, err)
 	}
 
 	_, err = io.Copy(out, in)
 	if err != nil {
-		return fmt.Errorf("%s: writing file: %v", fpath, err)
+		return fmt.Errorf("%s: writing file: %w", fpath, err)
 	}
 	return nil
 }
 
-func writeNewSymbolicLink(fpath string, target string) error {
-	err := os.MkdirAll(filepath.Dir(fpath), 0755)
+func writeNewSymbolicLink(fileSystem fs.FileSystem, fpath string, target string) error {
+	err := fileSystem.MkdirAll(filepath.Dir(fpath), 0755)
 	if err != nil {
-		return fmt.Errorf("%s: making directory for file: %v", fpath, err)
+		return fmt.Errorf("%s: making directory for file: %w", fpath, err)
 	}
 
-	_, err = os.Lstat(fpath)
+	_, err = fileSystem.Lstat(fpath)
 	if err == nil {
-		err = os.Remove(fpath)
+		err = fileSystem.Remove(fpath)
 		if err != nil {
-			return fmt.Errorf("%s: failed to unlink: %+v", fpath, err)
+			return fmt.Errorf("%s: failed to unlink: %w", fpath, err)
 		}
 	}
 
-	err = os.Symlink(target, fpath)
+	err = fileSystem.Symlink(target, fpath)
 	if err != nil {
-		return fmt.Errorf("%s: making symbolic link for: %v", fpath, err)
+		return fmt.Errorf("%s: making symbolic link for: %w", fpath, err)
 	}
 
 	return nil
 }
 
-func writeNewHardLink(fpath string, target string) error {
-	err := os.MkdirAll(filepath.Dir(fpath), 0755)
+func writeNewHardLink(fileSystem fs.FileSystem, fpath string, target string) error {
+	err := fileSystem.MkdirAll(filepath.Dir(fpath), 0755)
 	if err != nil {
-		return fmt.Errorf("%s: making directory for file: %v", fpath, err)
+		return fmt.Errorf("%s: making directory for file: %w", fpath, err)
 	}
 
-	_, err = os.Lstat(fpath)
+	_, err = fileSystem.Lstat(fpath)
 	if err == nil {
-		err = os.Remove(fpath)
+		err = fileSystem.Remove(fpath)
 		if err != nil {
-			return fmt.Errorf("%s: failed to unlink: %+v", fpath, err)
+			return fmt.Errorf("%s: failed to unlink: %w", fpath, err)
 		}
 	}
 
-	err = os.Link(target, fpath)
+	err = fileSystem.Link(target, fpath)
 	if err != nil {
-		return fmt.Errorf("%s: making hard link for: %v", fpath, err)
+		return fmt.Errorf("%s: making hard link for: %w", fpath, err)
 	}
 
 	return nil
 }
 
-func mkdir(dirPath string) error {
-	err := os.MkdirAll(dirPath, 0755)
+func mkdir(fileSystem fs.FileSystem, dirPath string) error {
+	err := fileSystem.MkdirAll(dirPath, 0755)
 	if err != nil {
-		return fmt.Errorf("%s: making directory: %v", dirPath, err)
+		return fmt.Errorf("%s: making directory: %w", dirPath, err)
 	}
 	return nil
 }
@@ -128,8 +196,121 @@ func sanitizeExtractPath(filePath string, destination string) error {
 	// the target path, and make sure it's nested in the intended
 	// destination, or bail otherwise.
 	destpath := filepath.Join(destination, filePath)
-	if !strings.HasPrefix(destpath, filepath.Clean(destination)) {
-		return fmt.Errorf("%s: illegal file path", filePath)
+	if !isInsideDestination(destpath, destination) {
+		return &ViolationError{Name: filePath, Violation: ViolationPathEscape}
+	}
+	return nil
+}
+
+// isInsideDestination is true when the clean path fpath is destination or a path in it
+func isInsideDestination(fpath string, destination string) bool {
+	destination = filepath.Clean(destination)
+	return fpath == destination || strings.HasPrefix(fpath, strings.TrimSuffix(destination, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// extraction is the state of an archive being extracted into destination,
+// it checks its entries against the rules of extraction in the order they are extracted.
+type extraction struct {
+	destination string
+	fileSystem  fs.FileSystem
+	options     ExtractOptions
+
+	bytes   int64
+	entries int
+}
+
+func newExtraction(destination string, fileSystem fs.FileSystem, options ExtractOptions) *extraction {
+	return &extraction{
+		destination: filepath.Clean(destination),
+		fileSystem:  fileSystem,
+		options:     options,
+	}
+}
+
+// checkEntry checks the entry name, whose contents are size bytes, can be extracted and returns its path.
+// When replacesLink is set, name itself can be an existing symbolic link as the entry replaces it.
+func (e *extraction) checkEntry(name string, size int64, replacesLink bool) (string, error) {
+	if err := sanitizeExtractPath(name, e.destination); err != nil {
+		return "", err
+	}
+
+	e.entries++
+	if e.options.MaxEntries > 0 && e.entries > e.options.MaxEntries {
+		return "", &ViolationError{Name: name, Violation: ViolationMaxEntries}
+	}
+	if e.options.MaxPathDepth > 0 && pathDepth(name) > e.options.MaxPathDepth {
+		return "", &ViolationError{Name: name, Violation: ViolationMaxPathDepth}
+	}
+	e.bytes += size
+	if e.options.MaxBytes > 0 && e.bytes > e.options.MaxBytes {
+		return "", &ViolationError{Name: name, Violation: ViolationMaxBytes}
+	}
+
+	destpath := filepath.Join(e.destination, name)
+	checkedPath := destpath
+	if replacesLink {
+		checkedPath = filepath.Dir(destpath)
+	}
+	if err := e.checkNoSymlink(name, checkedPath); err != nil {
+		return "", err
+	}
+	return destpath, nil
+}
+
+// checkSymlink checks the target of the symbolic link name extracted at destpath stays in the destination
+func (e *extraction) checkSymlink(name string, destpath string, target string) error {
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(destpath), target)
+	}
+	if !isInsideDestination(filepath.Clean(target), e.destination) {
+		return &ViolationError{Name: name, Violation: ViolationLinkEscape}
+	}
+	return nil
+}
+
+// checkHardLink checks the target of the hard link name, relative to the destination,
+// is in the destination and returns its path
+func (e *extraction) checkHardLink(name string, target string) (string, error) {
+	targetPath := filepath.Join(e.destination, target)
+	if !isInsideDestination(targetPath, e.destination) {
+		return "", &ViolationError{Name: name, Violation: ViolationLinkEscape}
+	}
+	if err := e.checkNoSymlink(name, filepath.Dir(targetPath)); err != nil {
+		return "", err
+	}
+	return targetPath, nil
+}
+
+// checkNoSymlink checks none of the elements of fpath in the destination is a symbolic link, which could lead
+// outside of it. Even when symbolic links point inside the destination, their parents may not be.
+func (e *extraction) checkNoSymlink(name string, fpath string) error {
+	relativePath, err := filepath.Rel(e.destination, fpath)
+	if err != nil || relativePath == "." {
+		return nil
+	}
+
+	current := e.destination
+	for _, element := range strings.Split(relativePath, string(filepath.Separator)) {
+		current = filepath.Join(current, element)
+		info, err := e.fileSystem.Lstat(current)
+		if err != nil {
+			// the rest of the path doesn't exist yet
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return &ViolationError{Name: name, Violation: ViolationSymlinkPath}
+		}
 	}
 	return nil
 }
+
+// pathDepth is the number of elements of the path name
+func pathDepth(name string) int {
+	depth := 0
+	for _, element := range strings.Split(filepath.ToSlash(filepath.Clean(name)), "/") {
+		if element != "" && element != "." {
+			depth++
+		}
+	}
+	return depth
+}
@@ -0,0 +1,302 @@
+package archiver
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingFileSystem writes to the local file system and records the paths of the files it creates
+type recordingFileSystem struct {
+	fs.FileSystem
+	created []string
+}
+
+func (r *recordingFileSystem) Create(name string) (fs.File, error) {
+	r.created = append(r.created, name)
+	return r.FileSystem.Create(name)
+}
+
+func (r *recordingFileSystem) Symlink(oldname, newname string) error {
+	r.created = append(r.created, newname)
+	return r.FileSystem.Symlink(oldname, newname)
+}
+
+func (r *recordingFileSystem) Link(oldname, newname string) error {
+	r.created = append(r.created, newname)
+	return r.FileSystem.Link(oldname, newname)
+}
+
+func TestTar_ReadWithOptions_ShouldWriteThroughFileSystem(t *testing.T) {
+	t.Parallel()
+	destination, _ := ioutil.TempDir("", "archiver")
+	defer os.RemoveAll(destination)
+
+	var tarBytes bytes.Buffer
+	tarWriter := tar.NewWriter(&tarBytes)
+	tarWriter.WriteHeader(&tar.Header{Name: "lib/", Typeflag: tar.TypeDir, Mode: 0755})
+	tarWriter.WriteHeader(&tar.Header{Name: "lib/library.so", Typeflag: tar.TypeReg, Mode: 0755, Size: 7})
+	tarWriter.Write([]byte("library"))
+	tarWriter.WriteHeader(&tar.Header{Name: "lib/library.so.1", Typeflag: tar.TypeSymlink, Linkname: "library.so"})
+	tarWriter.WriteHeader(&tar.Header{Name: "lib/library.so.2", Typeflag: tar.TypeLink, Linkname: "lib/library.so"})
+	tarWriter.Close()
+
+	fileSystem := &recordingFileSystem{FileSystem: fs.NewLocalFS()}
+	assert.Nil(t, Tar.ReadWithOptions(&tarBytes, destination, fileSystem, ExtractOptions{}))
+
+	assert.Equal(t, []string{
+		filepath.Join(destination, "lib", "library.so"),
+		filepath.Join(destination, "lib", "library.so.1"),
+		filepath.Join(destination, "lib", "library.so.2"),
+	}, fileSystem.created)
+	info, _ := os.Stat(filepath.Join(destination, "lib", "library.so"))
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	content, _ := ioutil.ReadFile(filepath.Join(destination, "lib", "library.so.2"))
+	assert.Equal(t, "library", string(content))
+}
+
+// metadataTar is a tar whose entries have times, owners and extended attributes
+func metadataTar(modTime time.Time) *bytes.Buffer {
+	var tarBytes bytes.Buffer
+	tarWriter := tar.NewWriter(&tarBytes)
+	tarWriter.WriteHeader(&tar.Header{Name: "bin/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: modTime, Uid: 1000, Gid: 1000})
+	tarWriter.WriteHeader(&tar.Header{
+		Name:       "bin/node",
+		Typeflag:   tar.TypeReg,
+		Mode:       0755,
+		Size:       4,
+		ModTime:    modTime,
+		Uid:        1000,
+		Gid:        1001,
+		Format:     tar.FormatPAX,
+		PAXRecords: map[string]string{"SCHILY.xattr.security.capability": "caps"},
+	})
+	tarWriter.Write([]byte("node"))
+	tarWriter.WriteHeader(&tar.Header{Name: "bin/node.1", Typeflag: tar.TypeSymlink, Linkname: "node", ModTime: modTime})
+	tarWriter.Close()
+	return &tarBytes
+}
+
+func TestTar_ReadWithOptions_WithDefaultOptions_ShouldOnlyKeepModes(t *testing.T) {
+	t.Parallel()
+	memFS := fs.NewMemFS()
+	modTime := time.Unix(1500000000, 0)
+
+	assert.Nil(t, Tar.ReadWithOptions(metadataTar(modTime), "/destination", memFS, ExtractOptions{}))
+
+	info, _ := memFS.Stat("/destination/bin/node")
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	assert.False(t, modTime.Equal(info.ModTime()))
+	assert.Equal(t, 0, info.Sys().(*fs.MemSys).Uid)
+	assert.Empty(t, info.Sys().(*fs.MemSys).Xattrs)
+}
+
+func TestTar_ReadWithOptions_WithPreserveModTimes_ShouldKeepModTimes(t *testing.T) {
+	t.Parallel()
+	memFS := fs.NewMemFS()
+	modTime := time.Unix(1500000000, 0)
+
+	assert.Nil(t, Tar.ReadWithOptions(metadataTar(modTime), "/destination", memFS, ExtractOptions{PreserveModTimes: true}))
+
+	// the directory keeps its time even though files were written into it after it was created
+	for _, name := range []string{"/destination/bin", "/destination/bin/node"} {
+		info, _ := memFS.Stat(name)
+		assert.True(t, modTime.Equal(info.ModTime()), name)
+	}
+}
+
+func TestTar_ReadWithOptions_WithPreserveXattrs_ShouldSetXattrsFromPAXRecords(t *testing.T) {
+	t.Parallel()
+	memFS := fs.NewMemFS()
+
+	assert.Nil(t, Tar.ReadWithOptions(metadataTar(time.Now()), "/destination", memFS, ExtractOptions{PreserveXattrs: true}))
+
+	info, _ := memFS.Stat("/destination/bin/node")
+	assert.Equal(t, map[string][]byte{"security.capability": []byte("caps")}, info.Sys().(*fs.MemSys).Xattrs)
+}
+
+func TestTar_ReadWithOptions_WithPreserveOwnership_ShouldKeepOwnersWhenRoot(t *testing.T) {
+	t.Parallel()
+	memFS := fs.NewMemFS()
+
+	assert.Nil(t, Tar.ReadWithOptions(metadataTar(time.Now()), "/destination", memFS, ExtractOptions{PreserveOwnership: true}))
+
+	info, _ := memFS.Stat("/destination/bin/node")
+	if isRoot() {
+		assert.Equal(t, 1000, info.Sys().(*fs.MemSys).Uid)
+		assert.Equal(t, 1001, info.Sys().(*fs.MemSys).Gid)
+	} else {
+		// files can only be given to other users by root, ownership is then ignored
+		assert.Equal(t, 0, info.Sys().(*fs.MemSys).Uid)
+	}
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+}
+
+// tarOf writes headers into a tar, regular files have their name as content
+func tarOf(headers ...*tar.Header) *bytes.Buffer {
+	var tarBytes bytes.Buffer
+	tarWriter := tar.NewWriter(&tarBytes)
+	for _, header := range headers {
+		if header.Typeflag == tar.TypeReg {
+			header.Size = int64(len(header.Name))
+		}
+		tarWriter.WriteHeader(header)
+		if header.Typeflag == tar.TypeReg {
+			tarWriter.Write([]byte(header.Name))
+		}
+	}
+	tarWriter.Close()
+	return &tarBytes
+}
+
+func violationOf(err error) Violation {
+	if violationErr, ok := err.(*ViolationError); ok {
+		return violationErr.Violation
+	}
+	return ""
+}
+
+func TestTar_ReadWithOptions_WithUnsafeEntries_ShouldReturnViolationErrors(t *testing.T) {
+	t.Parallel()
+	testCases := map[string]struct {
+		headers   []*tar.Header
+		violation Violation
+	}{
+		"path in a sibling of the destination": {
+			headers:   []*tar.Header{{Name: "../destination-sibling/file", Typeflag: tar.TypeReg}},
+			violation: ViolationPathEscape,
+		},
+		"relative symbolic link outside of the destination": {
+			headers:   []*tar.Header{{Name: "lib/passwd", Typeflag: tar.TypeSymlink, Linkname: "../../etc/passwd"}},
+			violation: ViolationLinkEscape,
+		},
+		"absolute symbolic link outside of the destination": {
+			headers:   []*tar.Header{{Name: "passwd", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}},
+			violation: ViolationLinkEscape,
+		},
+		"hard link outside of the destination": {
+			headers:   []*tar.Header{{Name: "passwd", Typeflag: tar.TypeLink, Linkname: "../etc/passwd"}},
+			violation: ViolationLinkEscape,
+		},
+		"file written through a symbolic link": {
+			headers: []*tar.Header{
+				{Name: "lib/", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "current", Typeflag: tar.TypeSymlink, Linkname: "."},
+				{Name: "current/file", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+			violation: ViolationSymlinkPath,
+		},
+	}
+
+	for name, testCase := range testCases {
+		memFS := fs.NewMemFS()
+		memFS.MkdirAll("/etc", 0755)
+		memFS.WriteFile("/etc/passwd", []byte("root"), 0644)
+
+		err := Tar.ReadWithOptions(tarOf(testCase.headers...), "/destination", memFS, ExtractOptions{})
+		assert.Equal(t, testCase.violation, violationOf(err), name)
+
+		content, _ := memFS.ReadFile("/etc/passwd")
+		assert.Equal(t, "root", string(content), name)
+		_, statErr := memFS.Stat("/destination-sibling/file")
+		assert.True(t, os.IsNotExist(statErr), name)
+	}
+}
+
+func TestTar_ReadWithOptions_WithLinksInDestination_ShouldExtract(t *testing.T) {
+	t.Parallel()
+	memFS := fs.NewMemFS()
+
+	assert.Nil(t, Tar.ReadWithOptions(tarOf(
+		&tar.Header{Name: "lib/versions/library.so.1", Typeflag: tar.TypeReg, Mode: 0644},
+		&tar.Header{Name: "lib/library.so", Typeflag: tar.TypeSymlink, Linkname: "versions/library.so.1"},
+		&tar.Header{Name: "lib/library.so", Typeflag: tar.TypeSymlink, Linkname: "./versions/library.so.1"},
+		&tar.Header{Name: "library.so", Typeflag: tar.TypeLink, Linkname: "lib/versions/library.so.1"},
+	), "/destination", memFS, ExtractOptions{}))
+
+	content, _ := memFS.ReadFile("/destination/lib/library.so")
+	assert.Equal(t, "lib/versions/library.so.1", string(content))
+	content, _ = memFS.ReadFile("/destination/library.so")
+	assert.Equal(t, "lib/versions/library.so.1", string(content))
+}
+
+func TestTar_ReadWithOptions_WithLimits_ShouldReturnViolationErrors(t *testing.T) {
+	t.Parallel()
+	headers := []*tar.Header{
+		{Name: "lib/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "lib/library.so", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "lib/library.so.1", Typeflag: tar.TypeSymlink, Linkname: "library.so"},
+	}
+	testCases := map[ExtractOptions]Violation{
+		{}:                "",
+		{MaxBytes: 14}:    "",
+		{MaxBytes: 13}:    ViolationMaxBytes,
+		{MaxEntries: 3}:   "",
+		{MaxEntries: 2}:   ViolationMaxEntries,
+		{MaxPathDepth: 2}: "",
+		{MaxPathDepth: 1}: ViolationMaxPathDepth,
+	}
+
+	for options, violation := range testCases {
+		err := Tar.ReadWithOptions(tarOf(headers...), "/destination", fs.NewMemFS(), options)
+		assert.Equal(t, violation, violationOf(err), "%+v", options)
+		if violation == "" {
+			assert.Nil(t, err, "%+v", options)
+		}
+	}
+}
+
+func TestTar_Make_WithSymbolicLink_ShouldKeepItsTarget(t *testing.T) {
+	t.Parallel()
+	tempDir, _ := ioutil.TempDir("", "archiver")
+	defer os.RemoveAll(tempDir)
+
+	source := filepath.Join(tempDir, "source")
+	os.MkdirAll(source, 0755)
+	ioutil.WriteFile(filepath.Join(source, "library.so"), []byte("library"), 0644)
+	os.Symlink("library.so", filepath.Join(source, "library.so.1"))
+
+	tarPath := filepath.Join(tempDir, "source.tar")
+	assert.Nil(t, Tar.Make(tarPath, []string{source}))
+	destination := filepath.Join(tempDir, "destination")
+	assert.Nil(t, Tar.Open(tarPath, destination))
+
+	target, _ := os.Readlink(filepath.Join(destination, "source", "library.so.1"))
+	assert.Equal(t, "library.so", target)
+}
+
+func TestCompressedTar_Make_ShouldBeReadable(t *testing.T) {
+	t.Parallel()
+	for _, format := range []Archiver{TarZst, TarXz, TarBz2} {
+		func() {
+			tempDir, _ := ioutil.TempDir("", "archiver")
+			defer os.RemoveAll(tempDir)
+
+			source := filepath.Join(tempDir, "source")
+			os.MkdirAll(filepath.Join(source, "lib"), 0755)
+			ioutil.WriteFile(filepath.Join(source, "setup.sh"), []byte("source me"), 0755)
+			ioutil.WriteFile(filepath.Join(source, "lib", "library.so"), bytes.Repeat([]byte("library"), 100000), 0644)
+			os.Symlink("library.so", filepath.Join(source, "lib", "library.so.1"))
+
+			archivePath := filepath.Join(tempDir, "overlay")
+			assert.Nil(t, format.Make(archivePath, []string{source}))
+
+			destination := filepath.Join(tempDir, "destination")
+			assert.Nil(t, format.Open(archivePath, destination))
+
+			content, _ := ioutil.ReadFile(filepath.Join(destination, "source", "setup.sh"))
+			assert.Equal(t, "source me", string(content))
+			info, _ := os.Stat(filepath.Join(destination, "source", "setup.sh"))
+			assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+			content, _ = ioutil.ReadFile(filepath.Join(destination, "source", "lib", "library.so.1"))
+			assert.Equal(t, bytes.Repeat([]byte("library"), 100000), content)
+		}()
+	}
+}
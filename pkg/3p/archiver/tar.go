@@ -9,6 +9,8 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
 )
 
 // Tar is for Tar format
@@ -26,6 +28,9 @@ func (tarFormat) Match(filename string) bool {
 
 const tarBlockSize int = 512
 
+// paxSchilyXattr is the prefix of the PAX records holding extended attributes
+const paxSchilyXattr = "SCHILY.xattr."
+
 // isTar checks the file has the Tar format header by reading its beginning
 // block.
 func isTar(tarPath string) bool {
@@ -140,7 +145,15 @@ func tarFile(tarWriter *tar.Writer, source, dest string) error {
 			return fmt.Errorf("error walking to %s: %v", path, err)
 		}
 
-		header, err := tar.FileInfoHeader(info, path)
+		var linkTarget string
+		if info.Mode()&os.ModeSymlink != 0 {
+			linkTarget, err = os.Readlink(path)
+			if err != nil {
+				return fmt.Errorf("%s: reading link: %v", path, err)
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, linkTarget)
 		if err != nil {
 			return fmt.Errorf("%s: making header: %v", path, err)
 		}
@@ -186,7 +199,14 @@ func tarFile(tarWriter *tar.Writer, source, dest string) error {
 // Read untars a .tar file read from a Reader and puts
 // the contents into destination.
 func (tarFormat) Read(input io.Reader, destination string) error {
-	return untar(tar.NewReader(input), destination)
+	return Tar.ReadWithOptions(input, destination, fs.NewLocalFS(), ExtractOptions{})
+}
+
+// ReadWithOptions untars a .tar file read from a Reader and puts
+// the contents into destination on fileSystem,
+// preserving the metadata options asks for.
+func (tarFormat) ReadWithOptions(input io.Reader, destination string, fileSystem fs.FileSystem, options ExtractOptions) error {
+	return untar(tar.NewReader(input), destination, fileSystem, options)
 }
 
 // Open untars source and puts the contents into destination.
@@ -201,7 +221,9 @@ func (tarFormat) Open(source, destination string) error {
 }
 
 // untar un-tarballs the contents of tr into destination.
-func untar(tr *tar.Reader, destination string) error {
+func untar(tr *tar.Reader, destination string, fileSystem fs.FileSystem, options ExtractOptions) error {
+	extraction := newExtraction(destination, fileSystem, options)
+	var directories []*tar.Header
 	for {
 		header, err := tr.Next()
 		if err == io.EOF {
@@ -210,35 +232,103 @@ func untar(tr *tar.Reader, destination string) error {
 			return err
 		}
 
-		if err := untarFile(tr, header, destination); err != nil {
+		if err := untarFile(tr, header, extraction); err != nil {
 			return err
 		}
+		if header.Typeflag == tar.TypeDir {
+			directories = append(directories, header)
+		}
+	}
+
+	// writing into a directory changes its modification time, so they are set last, deepest first
+	if options.PreserveModTimes {
+		for i := len(directories) - 1; i >= 0; i-- {
+			destpath := filepath.Join(destination, directories[i].Name)
+			if err := fileSystem.Chtimes(destpath, directories[i].AccessTime, directories[i].ModTime); err != nil {
+				return fmt.Errorf("%s: changing file times: %w", destpath, err)
+			}
+		}
 	}
 	return nil
 }
 
-// untarFile untars a single file from tr with header header into destination.
-func untarFile(tr *tar.Reader, header *tar.Header, destination string) error {
-	err := sanitizeExtractPath(header.Name, destination)
+// untarFile untars a single file from tr with header header into the destination of extraction.
+func untarFile(tr *tar.Reader, header *tar.Header, extraction *extraction) error {
+	if header.Typeflag == tar.TypeXGlobalHeader {
+		// ignore the pax global header from git generated tarballs
+		return nil
+	}
+
+	var size int64
+	if header.Typeflag == tar.TypeReg || header.Typeflag == tar.TypeRegA {
+		size = header.Size
+	}
+	isLink := header.Typeflag == tar.TypeSymlink || header.Typeflag == tar.TypeLink
+	destpath, err := extraction.checkEntry(header.Name, size, isLink)
 	if err != nil {
 		return err
 	}
 
-	destpath := filepath.Join(destination, header.Name)
-
+	fileSystem := extraction.fileSystem
 	switch header.Typeflag {
 	case tar.TypeDir:
-		return mkdir(destpath)
+		err = mkdir(fileSystem, destpath)
 	case tar.TypeReg, tar.TypeRegA, tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
-		return writeNewFile(destpath, tr, header.FileInfo().Mode())
+		err = writeNewFile(fileSystem, destpath, tr, header.FileInfo().Mode())
 	case tar.TypeSymlink:
-		return writeNewSymbolicLink(destpath, header.Linkname)
+		if err := extraction.checkSymlink(header.Name, destpath, header.Linkname); err != nil {
+			return err
+		}
+		err = writeNewSymbolicLink(fileSystem, destpath, header.Linkname)
 	case tar.TypeLink:
-		return writeNewHardLink(destpath, filepath.Join(destination, header.Linkname))
-	case tar.TypeXGlobalHeader:
-		// ignore the pax global header from git generated tarballs
-		return nil
+		targetPath, err := extraction.checkHardLink(header.Name, header.Linkname)
+		if err != nil {
+			return err
+		}
+		// a hard link shares the metadata of the file it links to
+		return writeNewHardLink(fileSystem, destpath, targetPath)
 	default:
 		return fmt.Errorf("%s: unknown type flag: %c", header.Name, header.Typeflag)
 	}
+	if err != nil {
+		return err
+	}
+	return setFileMetadata(fileSystem, destpath, header, extraction.options)
+}
+
+// setFileMetadata applies the metadata of header to the file written at fpath, as options ask.
+// The times of directories are set once their contents are written, by untar.
+func setFileMetadata(fileSystem fs.FileSystem, fpath string, header *tar.Header, options ExtractOptions) error {
+	isSymlink := header.Typeflag == tar.TypeSymlink
+
+	if options.PreserveOwnership && isRoot() {
+		if err := fileSystem.Lchown(fpath, header.Uid, header.Gid); err != nil {
+			return fmt.Errorf("%s: changing ownership: %w", fpath, err)
+		}
+		// changing the owner of a file clears its setuid and setgid bits
+		if !isSymlink && header.Typeflag != tar.TypeDir {
+			if err := fileSystem.Chmod(fpath, fs.FileMode(header.FileInfo().Mode())); err != nil {
+				return fmt.Errorf("%s: changing file mode: %w", fpath, err)
+			}
+		}
+	}
+
+	if options.PreserveXattrs && !isSymlink {
+		for key, value := range header.PAXRecords {
+			if !strings.HasPrefix(key, paxSchilyXattr) {
+				continue
+			}
+			if err := fileSystem.Setxattr(fpath, strings.TrimPrefix(key, paxSchilyXattr), []byte(value)); err != nil {
+				return fmt.Errorf("%s: setting extended attribute: %w", fpath, err)
+			}
+		}
+	}
+
+	// the times of a symbolic link would be set on its target
+	if options.PreserveModTimes && !isSymlink && header.Typeflag != tar.TypeDir {
+		if err := fileSystem.Chtimes(fpath, header.AccessTime, header.ModTime); err != nil {
+			return fmt.Errorf("%s: changing file times: %w", fpath, err)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,105 @@
+package archiver
+
+import (
+	"compress/bzip2"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	bzip2writer "github.com/dsnet/compress/bzip2"
+)
+
+// TarBz2 is for TarBz2 format
+var TarBz2 tarBz2Format
+
+func init() {
+	RegisterFormat("TarBz2", TarBz2)
+}
+
+type tarBz2Format struct{}
+
+func (tarBz2Format) Match(filename string) bool {
+	return strings.HasSuffix(strings.ToLower(filename), ".tar.bz2") ||
+		strings.HasSuffix(strings.ToLower(filename), ".tbz2") ||
+		strings.HasSuffix(strings.ToLower(filename), ".tbz") ||
+		isTarBz2(filename)
+}
+
+// isTarBz2 checks the file has the bzip2 compressed Tar format header by reading
+// its beginning block.
+func isTarBz2(tarbz2Path string) bool {
+	f, err := os.Open(tarbz2Path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	buf := make([]byte, tarBlockSize)
+	n, err := io.ReadFull(bzip2.NewReader(f), buf)
+	if err != nil || n < tarBlockSize {
+		return false
+	}
+
+	return hasTarHeader(buf)
+}
+
+// Write outputs a .tar.bz2 file to a Writer containing
+// the contents of files listed in filePaths. It works
+// the same way Tar does, but with bzip2 compression.
+func (tarBz2Format) Write(output io.Writer, filePaths []string) error {
+	return writeTarBz2(filePaths, output, "")
+}
+
+// Make creates a .tar.bz2 file at tarbz2Path containing
+// the contents of files listed in filePaths. It works
+// the same way Tar does, but with bzip2 compression.
+func (tarBz2Format) Make(tarbz2Path string, filePaths []string) error {
+	out, err := os.Create(tarbz2Path)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", tarbz2Path, err)
+	}
+	defer out.Close()
+
+	return writeTarBz2(filePaths, out, tarbz2Path)
+}
+
+// writeTarBz2 compresses at the block size of bzip2 -9, the default of the bzip2 command.
+// The standard library can only decompress bzip2.
+func writeTarBz2(filePaths []string, output io.Writer, dest string) error {
+	bz2w, err := bzip2writer.NewWriter(output, &bzip2writer.WriterConfig{Level: bzip2writer.BestCompression})
+	if err != nil {
+		return fmt.Errorf("error compressing: %v", err)
+	}
+
+	if err := writeTar(filePaths, bz2w, dest); err != nil {
+		bz2w.Close()
+		return err
+	}
+	return bz2w.Close()
+}
+
+// Read untars a .tar.bz2 file read from a Reader and decompresses
+// the contents into destination.
+func (tarBz2Format) Read(input io.Reader, destination string) error {
+	return TarBz2.ReadWithOptions(input, destination, fs.NewLocalFS(), ExtractOptions{})
+}
+
+// ReadWithOptions untars a .tar.bz2 file read from a Reader and decompresses
+// the contents into destination on fileSystem,
+// preserving the metadata options asks for.
+func (tarBz2Format) ReadWithOptions(input io.Reader, destination string, fileSystem fs.FileSystem, options ExtractOptions) error {
+	return Tar.ReadWithOptions(bzip2.NewReader(input), destination, fileSystem, options)
+}
+
+// Open untars source and decompresses the contents into destination.
+func (tarBz2Format) Open(source, destination string) error {
+	f, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("%s: failed to open archive: %v", source, err)
+	}
+	defer f.Close()
+
+	return TarBz2.Read(f, destination)
+}
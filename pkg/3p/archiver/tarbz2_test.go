@@ -0,0 +1,29 @@
+package archiver
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"testing"
+
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+// a .tar.bz2 containing a setup.sh file, made with: tar -cf - setup.sh | bzip2 -9
+const testTarBz2 = "QlpoOTFBWSZTWSC8RPEAAHl7hMqAAGBAAXUAAEBqQt4AAACACCAAdBpGp6mI0yGh6noCSmk0eoNA" +
+	"aGgr9TGMUIKSASJ6ONg8icEkDBAarPWySSsgHbjlV7xOyFWaqw40DA73dJf2wkRH6h42IiAyLuSK" +
+	"cKEgQXiJ4g=="
+
+func TestTarBz2_ReadWithOptions_ShouldExtract(t *testing.T) {
+	t.Parallel()
+	memFS := fs.NewMemFS()
+	archive, _ := base64.StdEncoding.DecodeString(testTarBz2)
+
+	assert.Nil(t, TarBz2.ReadWithOptions(bytes.NewReader(archive), "/destination", memFS, ExtractOptions{}))
+
+	f, openErr := memFS.Open("/destination/setup.sh")
+	assert.Nil(t, openErr)
+	content, _ := ioutil.ReadAll(f)
+	assert.Equal(t, "source me", string(content))
+}
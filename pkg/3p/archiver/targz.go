@@ -6,6 +6,8 @@ import (
 	"io"
 	"os"
 	"strings"
+
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
 )
 
 // TarGz is for TarGz format
@@ -77,13 +79,20 @@ func writeTarGz(filePaths []string, output io.Writer, dest string) error {
 // Read untars a .tar.gz file read from a Reader and decompresses
 // the contents into destination.
 func (tarGzFormat) Read(input io.Reader, destination string) error {
+	return TarGz.ReadWithOptions(input, destination, fs.NewLocalFS(), ExtractOptions{})
+}
+
+// ReadWithOptions untars a .tar.gz file read from a Reader and decompresses
+// the contents into destination on fileSystem,
+// preserving the metadata options asks for.
+func (tarGzFormat) ReadWithOptions(input io.Reader, destination string, fileSystem fs.FileSystem, options ExtractOptions) error {
 	gzr, err := gzip.NewReader(input)
 	if err != nil {
 		return fmt.Errorf("error decompressing: %v", err)
 	}
 	defer gzr.Close()
 
-	return Tar.Read(gzr, destination)
+	return Tar.ReadWithOptions(gzr, destination, fileSystem, options)
 }
 
 // Open untars source and decompresses the contents into destination.
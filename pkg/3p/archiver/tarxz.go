@@ -0,0 +1,111 @@
+package archiver
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"github.com/ulikunitz/xz"
+)
+
+// TarXz is for TarXz format
+var TarXz tarXzFormat
+
+func init() {
+	RegisterFormat("TarXz", TarXz)
+}
+
+type tarXzFormat struct{}
+
+func (tarXzFormat) Match(filename string) bool {
+	return strings.HasSuffix(strings.ToLower(filename), ".tar.xz") ||
+		strings.HasSuffix(strings.ToLower(filename), ".txz") ||
+		isTarXz(filename)
+}
+
+// isTarXz checks the file has the xz compressed Tar format header by reading
+// its beginning block.
+func isTarXz(tarxzPath string) bool {
+	f, err := os.Open(tarxzPath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	xzr, err := xz.NewReader(f)
+	if err != nil {
+		return false
+	}
+
+	buf := make([]byte, tarBlockSize)
+	n, err := io.ReadFull(xzr, buf)
+	if err != nil || n < tarBlockSize {
+		return false
+	}
+
+	return hasTarHeader(buf)
+}
+
+// Write outputs a .tar.xz file to a Writer containing
+// the contents of files listed in filePaths. It works
+// the same way Tar does, but with xz compression.
+func (tarXzFormat) Write(output io.Writer, filePaths []string) error {
+	return writeTarXz(filePaths, output, "")
+}
+
+// Make creates a .tar.xz file at tarxzPath containing
+// the contents of files listed in filePaths. It works
+// the same way Tar does, but with xz compression.
+func (tarXzFormat) Make(tarxzPath string, filePaths []string) error {
+	out, err := os.Create(tarxzPath)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", tarxzPath, err)
+	}
+	defer out.Close()
+
+	return writeTarXz(filePaths, out, tarxzPath)
+}
+
+func writeTarXz(filePaths []string, output io.Writer, dest string) error {
+	xzw, err := xz.NewWriter(output)
+	if err != nil {
+		return fmt.Errorf("error compressing: %v", err)
+	}
+
+	if err := writeTar(filePaths, xzw, dest); err != nil {
+		xzw.Close()
+		return err
+	}
+	return xzw.Close()
+}
+
+// Read untars a .tar.xz file read from a Reader and decompresses
+// the contents into destination.
+func (tarXzFormat) Read(input io.Reader, destination string) error {
+	return TarXz.ReadWithOptions(input, destination, fs.NewLocalFS(), ExtractOptions{})
+}
+
+// ReadWithOptions untars a .tar.xz file read from a Reader and decompresses
+// the contents into destination on fileSystem,
+// preserving the metadata options asks for.
+func (tarXzFormat) ReadWithOptions(input io.Reader, destination string, fileSystem fs.FileSystem, options ExtractOptions) error {
+	xzr, err := xz.NewReader(input)
+	if err != nil {
+		return fmt.Errorf("error decompressing: %v", err)
+	}
+
+	return Tar.ReadWithOptions(xzr, destination, fileSystem, options)
+}
+
+// Open untars source and decompresses the contents into destination.
+func (tarXzFormat) Open(source, destination string) error {
+	f, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("%s: failed to open archive: %v", source, err)
+	}
+	defer f.Close()
+
+	return TarXz.Read(f, destination)
+}
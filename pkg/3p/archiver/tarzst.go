@@ -0,0 +1,113 @@
+package archiver
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"github.com/klauspost/compress/zstd"
+)
+
+// TarZst is for TarZst format
+var TarZst tarZstFormat
+
+func init() {
+	RegisterFormat("TarZst", TarZst)
+}
+
+type tarZstFormat struct{}
+
+func (tarZstFormat) Match(filename string) bool {
+	return strings.HasSuffix(strings.ToLower(filename), ".tar.zst") ||
+		strings.HasSuffix(strings.ToLower(filename), ".tzst") ||
+		isTarZst(filename)
+}
+
+// isTarZst checks the file has the zstd compressed Tar format header by reading
+// its beginning block.
+func isTarZst(tarzstPath string) bool {
+	f, err := os.Open(tarzstPath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	zr, err := zstd.NewReader(f)
+	if err != nil {
+		return false
+	}
+	defer zr.Close()
+
+	buf := make([]byte, tarBlockSize)
+	n, err := io.ReadFull(zr, buf)
+	if err != nil || n < tarBlockSize {
+		return false
+	}
+
+	return hasTarHeader(buf)
+}
+
+// Write outputs a .tar.zst file to a Writer containing
+// the contents of files listed in filePaths. It works
+// the same way Tar does, but with zstd compression.
+func (tarZstFormat) Write(output io.Writer, filePaths []string) error {
+	return writeTarZst(filePaths, output, "")
+}
+
+// Make creates a .tar.zst file at tarzstPath containing
+// the contents of files listed in filePaths. It works
+// the same way Tar does, but with zstd compression.
+func (tarZstFormat) Make(tarzstPath string, filePaths []string) error {
+	out, err := os.Create(tarzstPath)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", tarzstPath, err)
+	}
+	defer out.Close()
+
+	return writeTarZst(filePaths, out, tarzstPath)
+}
+
+func writeTarZst(filePaths []string, output io.Writer, dest string) error {
+	zw, err := zstd.NewWriter(output)
+	if err != nil {
+		return fmt.Errorf("error compressing: %v", err)
+	}
+
+	if err := writeTar(filePaths, zw, dest); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// Read untars a .tar.zst file read from a Reader and decompresses
+// the contents into destination.
+func (tarZstFormat) Read(input io.Reader, destination string) error {
+	return TarZst.ReadWithOptions(input, destination, fs.NewLocalFS(), ExtractOptions{})
+}
+
+// ReadWithOptions untars a .tar.zst file read from a Reader and decompresses
+// the contents into destination on fileSystem,
+// preserving the metadata options asks for.
+func (tarZstFormat) ReadWithOptions(input io.Reader, destination string, fileSystem fs.FileSystem, options ExtractOptions) error {
+	zr, err := zstd.NewReader(input)
+	if err != nil {
+		return fmt.Errorf("error decompressing: %v", err)
+	}
+	defer zr.Close()
+
+	return Tar.ReadWithOptions(zr, destination, fileSystem, options)
+}
+
+// Open untars source and decompresses the contents into destination.
+func (tarZstFormat) Open(source, destination string) error {
+	f, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("%s: failed to open archive: %v", source, err)
+	}
+	defer f.Close()
+
+	return TarZst.Read(f, destination)
+}
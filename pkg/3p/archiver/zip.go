@@ -0,0 +1,322 @@
+package archiver
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"github.com/google/uuid"
+)
+
+// Zip is for Zip format
+var Zip zipFormat
+
+func init() {
+	RegisterFormat("Zip", Zip)
+}
+
+type zipFormat struct{}
+
+// Match only looks at the suffix of filename, which is the name of an overlay rather than a local file
+func (zipFormat) Match(filename string) bool {
+	return strings.HasSuffix(strings.ToLower(filename), ".zip")
+}
+
+// isZip checks r starts with the signature of a zip local file header, or of the end of an empty zip
+func isZip(r io.ReaderAt) bool {
+	buf := make([]byte, 4)
+	if _, err := r.ReadAt(buf, 0); err != nil {
+		return false
+	}
+	return bytes.Equal(buf, []byte("PK\x03\x04")) || bytes.Equal(buf, []byte("PK\x05\x06"))
+}
+
+// Write outputs a .zip file to a Writer containing the
+// contents of files listed in filePaths. File paths can
+// be those of regular files or directories. Regular
+// files are stored at the 'root' of the archive, and
+// directories are recursively added.
+func (zipFormat) Write(output io.Writer, filePaths []string) error {
+	return writeZip(filePaths, output, "")
+}
+
+// Make creates a .zip file at zipPath containing the
+// contents of files listed in filePaths. File paths can
+// be those of regular files or directories. Regular
+// files are stored at the 'root' of the archive, and
+// directories are recursively added.
+func (zipFormat) Make(zipPath string, filePaths []string) error {
+	out, err := os.Create(zipPath)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", zipPath, err)
+	}
+	defer out.Close()
+
+	return writeZip(filePaths, out, zipPath)
+}
+
+func writeZip(filePaths []string, output io.Writer, dest string) error {
+	zipWriter := zip.NewWriter(output)
+
+	for _, fpath := range filePaths {
+		if err := zipFile(zipWriter, fpath, dest); err != nil {
+			zipWriter.Close()
+			return err
+		}
+	}
+	return zipWriter.Close()
+}
+
+// zipFile writes the file at source into zipWriter. It does so
+// recursively for directories.
+func zipFile(zipWriter *zip.Writer, source, dest string) error {
+	sourceInfo, err := os.Stat(source)
+	if err != nil {
+		return fmt.Errorf("%s: stat: %v", source, err)
+	}
+
+	var baseDir string
+	if sourceInfo.IsDir() {
+		baseDir = filepath.Base(source)
+	}
+
+	return filepath.Walk(source, func(fpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("error walking to %s: %v", fpath, err)
+		}
+
+		if fpath == dest {
+			// our new zip file is inside the directory being archived; skip it
+			return nil
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return fmt.Errorf("%s: making header: %v", fpath, err)
+		}
+
+		if baseDir != "" {
+			name, err := filepath.Rel(source, fpath)
+			if err != nil {
+				return err
+			}
+			header.Name = path.Join(baseDir, filepath.ToSlash(name))
+		}
+
+		if info.IsDir() {
+			header.Name += "/"
+			header.Method = zip.Store
+		} else {
+			header.Method = zip.Deflate
+		}
+
+		writer, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return fmt.Errorf("%s: making header: %v", fpath, err)
+		}
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			// the target of a symbolic link is stored as its content
+			target, err := os.Readlink(fpath)
+			if err != nil {
+				return fmt.Errorf("%s: reading link: %v", fpath, err)
+			}
+			_, err = io.WriteString(writer, target)
+			return err
+		case info.Mode().IsRegular():
+			file, err := os.Open(fpath)
+			if err != nil {
+				return fmt.Errorf("%s: open: %v", fpath, err)
+			}
+			defer file.Close()
+
+			_, err = io.CopyN(writer, file, info.Size())
+			if err != nil && err != io.EOF {
+				return fmt.Errorf("%s: copying contents: %v", fpath, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Read unzips the .zip file read from input into destination.
+// The central directory of a zip file is at its end, input is read
+// in place if it can seek and is spooled to a temporary file otherwise.
+func (zipFormat) Read(input io.Reader, destination string) error {
+	return Zip.ReadWithOptions(input, destination, fs.NewLocalFS(), ExtractOptions{})
+}
+
+// ReadWithOptions unzips the .zip file read from input into destination on fileSystem, see Read.
+// Zip files only keep modification times, the other options are ignored.
+func (zipFormat) ReadWithOptions(input io.Reader, destination string, fileSystem fs.FileSystem, options ExtractOptions) error {
+	readerAt, size, cleanup, err := zipReaderAt(input, destination, fileSystem)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	if !isZip(readerAt) {
+		return fmt.Errorf("error reading zip: not a zip file")
+	}
+	zipReader, err := zip.NewReader(readerAt, size)
+	if err != nil {
+		return fmt.Errorf("error reading zip: %v", err)
+	}
+	return unzip(zipReader, destination, fileSystem, options)
+}
+
+// Open unzips source into destination.
+func (zipFormat) Open(source, destination string) error {
+	f, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("%s: failed to open archive: %v", source, err)
+	}
+	defer f.Close()
+
+	return Zip.Read(f, destination)
+}
+
+// zipReaderAt gives random access to input, and a function to release what was needed for it.
+// Inputs which can't seek are spooled to a file in destination on fileSystem, which is removed by the
+// function, so that they take room where the archive is extracted rather than in the temporary directory.
+func zipReaderAt(input io.Reader, destination string, fileSystem fs.FileSystem) (io.ReaderAt, int64, func(), error) {
+	if seeker, ok := input.(io.ReadSeeker); ok {
+		size, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("error seeking zip: %v", err)
+		}
+		if readerAt, ok := input.(io.ReaderAt); ok {
+			return readerAt, size, func() {}, nil
+		}
+		return &seekingReaderAt{r: seeker}, size, func() {}, nil
+	}
+
+	if err := mkdir(fileSystem, destination); err != nil {
+		return nil, 0, nil, err
+	}
+	// the name is unknown to the archive, so none of its entries replaces the spool
+	spoolPath := filepath.Join(destination, ".zip-"+uuid.New().String()+".spool")
+	spool, err := fileSystem.Create(spoolPath)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("error creating spool file: %v", err)
+	}
+	cleanup := func() {
+		spool.Close()
+		fileSystem.Remove(spoolPath)
+	}
+
+	size, err := io.Copy(spool, input)
+	if err != nil {
+		cleanup()
+		return nil, 0, nil, fmt.Errorf("error reading zip: %v", err)
+	}
+	return spool, size, cleanup, nil
+}
+
+// seekingReaderAt reads at an offset by seeking r first, it can't be used concurrently
+type seekingReaderAt struct {
+	r io.ReadSeeker
+}
+
+func (s *seekingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if _, err := s.r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(s.r, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// unzip extracts the files of zipReader into destination.
+func unzip(zipReader *zip.Reader, destination string, fileSystem fs.FileSystem, options ExtractOptions) error {
+	extraction := newExtraction(destination, fileSystem, options)
+	var directories []*zip.File
+	for _, file := range zipReader.File {
+		if err := unzipFile(file, extraction); err != nil {
+			return err
+		}
+		if isZipDirectory(file) {
+			directories = append(directories, file)
+		}
+	}
+
+	// writing into a directory changes its modification time, so they are set last, deepest first
+	if options.PreserveModTimes {
+		for i := len(directories) - 1; i >= 0; i-- {
+			destpath := filepath.Join(destination, directories[i].Name)
+			if err := fileSystem.Chtimes(destpath, directories[i].Modified, directories[i].Modified); err != nil {
+				return fmt.Errorf("%s: changing file times: %w", destpath, err)
+			}
+		}
+	}
+	return nil
+}
+
+func isZipDirectory(file *zip.File) bool {
+	return file.FileInfo().IsDir() || strings.HasSuffix(file.Name, "/")
+}
+
+// maxSymlinkTargetLength is the longest target of a symbolic link read from a zip, PATH_MAX on Linux
+const maxSymlinkTargetLength = 4096
+
+// unzipFile extracts a single file of a zip into the destination of extraction.
+func unzipFile(file *zip.File, extraction *extraction) error {
+	isSymlink := file.Mode()&os.ModeSymlink != 0
+	var size int64
+	if !isZipDirectory(file) {
+		// the zip reader fails files whose contents are larger than their header says,
+		// the contents of a symbolic link are its target
+		size = int64(file.UncompressedSize64)
+	}
+	destpath, err := extraction.checkEntry(file.Name, size, isSymlink)
+	if err != nil {
+		return err
+	}
+
+	fileSystem := extraction.fileSystem
+	if isZipDirectory(file) {
+		return mkdir(fileSystem, destpath)
+	}
+
+	rc, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("%s: open compressed file: %v", file.Name, err)
+	}
+	defer rc.Close()
+
+	if isSymlink {
+		target, err := ioutil.ReadAll(io.LimitReader(rc, maxSymlinkTargetLength+1))
+		if err != nil {
+			return fmt.Errorf("%s: reading link: %v", file.Name, err)
+		}
+		if len(target) > maxSymlinkTargetLength {
+			return &ViolationError{Name: file.Name, Violation: ViolationLinkTargetLength}
+		}
+		if err := extraction.checkSymlink(file.Name, destpath, string(target)); err != nil {
+			return err
+		}
+		return writeNewSymbolicLink(fileSystem, destpath, string(target))
+	}
+
+	err = writeNewFile(fileSystem, destpath, rc, file.Mode())
+	if err != nil {
+		return err
+	}
+
+	if extraction.options.PreserveModTimes {
+		if err := fileSystem.Chtimes(destpath, file.Modified, file.Modified); err != nil {
+			return fmt.Errorf("%s: changing file times: %w", destpath, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package archiver
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZip_Make_ShouldBeReadable(t *testing.T) {
+	t.Parallel()
+	tempDir, _ := ioutil.TempDir("", "zip")
+	defer os.RemoveAll(tempDir)
+
+	source := filepath.Join(tempDir, "source")
+	os.MkdirAll(filepath.Join(source, "lib"), 0755)
+	ioutil.WriteFile(filepath.Join(source, "setup.sh"), []byte("source me"), 0755)
+	ioutil.WriteFile(filepath.Join(source, "lib", "library.so"), []byte("library"), 0644)
+	os.Symlink("library.so", filepath.Join(source, "lib", "library.so.1"))
+
+	zipPath := filepath.Join(tempDir, "overlay.zip")
+	assert.Nil(t, Zip.Make(zipPath, []string{source}))
+
+	// read without seeking, from a reader which is spooled to a temporary file
+	zipBytes, _ := ioutil.ReadFile(zipPath)
+	destination := filepath.Join(tempDir, "destination")
+	assert.Nil(t, Zip.Read(ioutil.NopCloser(bytes.NewReader(zipBytes)), destination))
+
+	content, _ := ioutil.ReadFile(filepath.Join(destination, "source", "setup.sh"))
+	assert.Equal(t, "source me", string(content))
+	info, _ := os.Stat(filepath.Join(destination, "source", "setup.sh"))
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	target, _ := os.Readlink(filepath.Join(destination, "source", "lib", "library.so.1"))
+	assert.Equal(t, "library.so", target)
+	content, _ = ioutil.ReadFile(filepath.Join(destination, "source", "lib", "library.so.1"))
+	assert.Equal(t, "library", string(content))
+}
+
+func TestZip_Read_WithPathOutsideOfDestination_ShouldReturnError(t *testing.T) {
+	t.Parallel()
+	tempDir, _ := ioutil.TempDir("", "zip")
+	defer os.RemoveAll(tempDir)
+
+	var zipBytes bytes.Buffer
+	zipWriter := zip.NewWriter(&zipBytes)
+	fileWriter, _ := zipWriter.Create("../escaped.txt")
+	fileWriter.Write([]byte("escaped"))
+	zipWriter.Close()
+
+	destination := filepath.Join(tempDir, "destination")
+	assert.NotNil(t, Zip.Read(bytes.NewReader(zipBytes.Bytes()), destination))
+	_, statErr := os.Stat(filepath.Join(tempDir, "escaped.txt"))
+	assert.True(t, os.IsNotExist(statErr))
+}
+
+func TestZip_ReadWithOptions_WithPreserveModTimes_ShouldKeepModTimes(t *testing.T) {
+	t.Parallel()
+	memFS := fs.NewMemFS()
+	modTime := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	var zipBytes bytes.Buffer
+	zipWriter := zip.NewWriter(&zipBytes)
+	zipWriter.CreateHeader(&zip.FileHeader{Name: "lib/", Modified: modTime})
+	fileWriter, _ := zipWriter.CreateHeader(&zip.FileHeader{Name: "lib/library.so", Method: zip.Deflate, Modified: modTime})
+	fileWriter.Write([]byte("library"))
+	zipWriter.Close()
+
+	assert.Nil(t, Zip.ReadWithOptions(bytes.NewReader(zipBytes.Bytes()), "/destination", memFS, ExtractOptions{PreserveModTimes: true}))
+
+	for _, name := range []string{"/destination/lib", "/destination/lib/library.so"} {
+		info, _ := memFS.Stat(name)
+		assert.True(t, modTime.Equal(info.ModTime()), name)
+	}
+}
+
+func TestZip_Read_WithSymbolicLinkOutsideOfDestination_ShouldReturnViolationError(t *testing.T) {
+	t.Parallel()
+	memFS := fs.NewMemFS()
+
+	var zipBytes bytes.Buffer
+	zipWriter := zip.NewWriter(&zipBytes)
+	header := &zip.FileHeader{Name: "passwd"}
+	header.SetMode(os.ModeSymlink | 0777)
+	fileWriter, _ := zipWriter.CreateHeader(header)
+	fileWriter.Write([]byte("../etc/passwd"))
+	zipWriter.Close()
+
+	err := Zip.ReadWithOptions(bytes.NewReader(zipBytes.Bytes()), "/destination", memFS, ExtractOptions{})
+	assert.Equal(t, ViolationLinkEscape, violationOf(err))
+	_, statErr := memFS.Lstat("/destination/passwd")
+	assert.True(t, os.IsNotExist(statErr))
+}
+
+func TestZip_Read_WithSymbolicLinkTooLong_ShouldReturnViolationError(t *testing.T) {
+	t.Parallel()
+	memFS := fs.NewMemFS()
+
+	var zipBytes bytes.Buffer
+	zipWriter := zip.NewWriter(&zipBytes)
+	header := &zip.FileHeader{Name: "link"}
+	header.SetMode(os.ModeSymlink | 0777)
+	fileWriter, _ := zipWriter.CreateHeader(header)
+	fileWriter.Write(bytes.Repeat([]byte("a/"), maxSymlinkTargetLength))
+	zipWriter.Close()
+
+	err := Zip.ReadWithOptions(bytes.NewReader(zipBytes.Bytes()), "/destination", memFS, ExtractOptions{})
+	assert.Equal(t, ViolationLinkTargetLength, violationOf(err))
+	_, statErr := memFS.Lstat("/destination/link")
+	assert.True(t, os.IsNotExist(statErr))
+}
+
+func TestZip_Read_WithSymbolicLinkPastMaxBytes_ShouldReturnViolationError(t *testing.T) {
+	t.Parallel()
+	memFS := fs.NewMemFS()
+
+	var zipBytes bytes.Buffer
+	zipWriter := zip.NewWriter(&zipBytes)
+	header := &zip.FileHeader{Name: "link"}
+	header.SetMode(os.ModeSymlink | 0777)
+	fileWriter, _ := zipWriter.CreateHeader(header)
+	fileWriter.Write([]byte("target"))
+	zipWriter.Close()
+
+	err := Zip.ReadWithOptions(bytes.NewReader(zipBytes.Bytes()), "/destination", memFS, ExtractOptions{MaxBytes: 5})
+	assert.Equal(t, ViolationMaxBytes, violationOf(err))
+}
+
+func TestZip_Match_ShouldOnlyMatchSuffix(t *testing.T) {
+	t.Parallel()
+	tempDir, _ := ioutil.TempDir("", "zip")
+	defer os.RemoveAll(tempDir)
+
+	zipPath := filepath.Join(tempDir, "overlay")
+	assert.Nil(t, Zip.Make(zipPath, []string{tempDir}))
+
+	assert.True(t, Zip.Match("overlay.ZIP"))
+	assert.False(t, Zip.Match(zipPath))
+}
+
+func TestZip_Read_WithoutZipSignature_ShouldReturnError(t *testing.T) {
+	t.Parallel()
+	memFS := fs.NewMemFS()
+
+	err := Zip.ReadWithOptions(bytes.NewReader([]byte("not a zip")), "/destination", memFS, ExtractOptions{})
+	assert.EqualError(t, err, "error reading zip: not a zip file")
+}
+
+func TestZip_ReadWithOptions_WithoutSeeking_ShouldSpoolOnFileSystem(t *testing.T) {
+	t.Parallel()
+	memFS := fs.NewMemFS()
+
+	var zipBytes bytes.Buffer
+	zipWriter := zip.NewWriter(&zipBytes)
+	fileWriter, _ := zipWriter.Create("setup.sh")
+	fileWriter.Write([]byte("source me"))
+	zipWriter.Close()
+
+	err := Zip.ReadWithOptions(ioutil.NopCloser(&zipBytes), "/destination", memFS, ExtractOptions{})
+
+	assert.Nil(t, err)
+	content, _ := memFS.ReadFile("/destination/setup.sh")
+	assert.Equal(t, "source me", string(content))
+	// the spool file is removed once extracted
+	infos, _ := memFS.ReadDir("/destination")
+	assert.Equal(t, 1, len(infos))
+}
@@ -8,6 +8,7 @@ package bundle
 import (
 	"archive/tar"
 	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
@@ -23,10 +24,13 @@ const (
 type archive struct {
 	version         string
 	inputStream     io.ReadSeeker
+	source          string
+	contentID       string
 	bundleProcessor bundleProcessor
 }
 
-func newBundleArchive(inputStream io.ReadSeeker) (*archive, error) {
+// source is the URL inputStream was opened from, contentID the content ID given by its streamer
+func newBundleArchive(inputStream io.ReadSeeker, source string, contentID string, config processorConfig) (*archive, error) {
 	// read version to determine bundle version
 	tarReader := tarReaderFromStream(inputStream)
 	version, versionErr := readVersionFromBundle(tarReader)
@@ -36,9 +40,9 @@ func newBundleArchive(inputStream io.ReadSeeker) (*archive, error) {
 	}
 
 	// get the appropriate bundle processor for the version
-	bundleProcessor := processorForVersion(version)
+	bundleProcessor := processorForVersion(version, config)
 	if bundleProcessor == nil {
-		return nil, fmt.Errorf("unsuppported bundle processor version: %s", version)
+		return nil, newBundleError(fmt.Errorf("unsupported bundle processor version: %s", version), ErrorTypeUnsupportedVersion)
 	}
 
 	// reset seek position to start of the stream and init with the stream
@@ -46,6 +50,8 @@ func newBundleArchive(inputStream io.ReadSeeker) (*archive, error) {
 	return &archive{
 		version:         version,
 		inputStream:     inputStream,
+		source:          source,
+		contentID:       contentID,
 		bundleProcessor: bundleProcessor,
 	}, nil
 }
@@ -55,15 +61,14 @@ func (b *archive) Version() string {
 	return b.version
 }
 
-// Extract everything into the cache
-func (b *archive) Extract(bundleStore Cache) (Bundle, error) {
-	return b.bundleProcessor.extract(b.inputStream, bundleStore)
+// Extract everything into the cache, keeping the file metadata options asks for
+func (b *archive) Extract(ctx context.Context, bundleStore Cache, options ExtractOptions) (Bundle, error) {
+	return b.bundleProcessor.extract(ctx, b.inputStream, bundleStore, b.source, b.contentID, options)
 }
 
 func readVersionFromBundle(tarReader *tar.Reader) (string, error) {
 	header, headerErr := tarReader.Next()
 	if headerErr != nil {
-		fmt.Printf("bundleError parsing tar: %v", headerErr)
 		return "", headerErr
 	}
 
@@ -3,35 +3,119 @@
 
 package bundle
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
 
 const (
-	ErrorTypeContentID  = "CONTENT_ID"
-	ErrorTypeSource     = "SOURCE"
-	ErrorTypeFormat     = "FORMAT"
-	ErrorTypeExtraction = "EXTRACTION"
+	ErrorTypeContentID          = "CONTENT_ID"
+	ErrorTypeSource             = "SOURCE"
+	ErrorTypeFormat             = "FORMAT"
+	ErrorTypeExtraction         = "EXTRACTION"
+	ErrorTypeIntegrity          = "INTEGRITY"
+	ErrorTypeCanceled           = "CANCELED"
+	ErrorTypeNotFound           = "NOT_FOUND"
+	ErrorTypeAccessDenied       = "ACCESS_DENIED"
+	ErrorTypeUnsupportedVersion = "UNSUPPORTED_VERSION"
+	ErrorTypeOutOfSpace         = "OUT_OF_SPACE"
+)
+
+// Sentinel errors of each error type, an *Error matches the one of its type with errors.Is:
+//
+//	if errors.Is(err, bundle.ErrNotFound) {
+var (
+	ErrContentID          = errors.New("bundle content ID mismatch")
+	ErrSource             = errors.New("bundle source error")
+	ErrFormat             = errors.New("bundle format error")
+	ErrExtraction         = errors.New("bundle extraction error")
+	ErrIntegrity          = errors.New("bundle integrity error")
+	ErrCanceled           = errors.New("bundle canceled")
+	ErrNotFound           = errors.New("bundle not found")
+	ErrAccessDenied       = errors.New("bundle access denied")
+	ErrUnsupportedVersion = errors.New("bundle version unsupported")
+	ErrOutOfSpace         = errors.New("bundle out of space")
 )
 
-type bundleError struct {
+var errorTypeSentinels = map[string]error{
+	ErrorTypeContentID:          ErrContentID,
+	ErrorTypeSource:             ErrSource,
+	ErrorTypeFormat:             ErrFormat,
+	ErrorTypeExtraction:         ErrExtraction,
+	ErrorTypeIntegrity:          ErrIntegrity,
+	ErrorTypeCanceled:           ErrCanceled,
+	ErrorTypeNotFound:           ErrNotFound,
+	ErrorTypeAccessDenied:       ErrAccessDenied,
+	ErrorTypeUnsupportedVersion: ErrUnsupportedVersion,
+	ErrorTypeOutOfSpace:         ErrOutOfSpace,
+}
+
+// Error is returned by the Provider, its type is one of the ErrorType constants.
+// Use errors.As to get it, or errors.Is with the sentinel error of a type.
+// The cause it wraps is also available to errors.Is and errors.As.
+type Error struct {
 	cause     error
 	errorType string
 }
 
-func (err *bundleError) Error() string {
+func (err *Error) Error() string {
 	return fmt.Sprintf("[%s] bundle error: %v", err.errorType, err.cause)
 }
 
-func (err *bundleError) GetCause() error {
+func (err *Error) GetCause() error {
 	return err.cause
 }
 
-func (err *bundleError) GetErrorType() string {
+func (err *Error) GetErrorType() string {
 	return err.errorType
 }
 
-func newBundleError(err error, errorType string) *bundleError {
-	return &bundleError{
+func (err *Error) Unwrap() error {
+	return err.cause
+}
+
+// Is matches the sentinel error of the type of err
+func (err *Error) Is(target error) bool {
+	sentinel, ok := errorTypeSentinels[err.errorType]
+	return ok && target == sentinel
+}
+
+func newBundleError(err error, errorType string) *Error {
+	return &Error{
 		cause:     err,
 		errorType: errorType,
 	}
 }
+
+// newBundleErrorWithContext reports errors happening once ctx is done as cancellations,
+// keeps the type of bundle errors, and refines errorType with the cause of other errors.
+func newBundleErrorWithContext(ctx context.Context, err error, errorType string) *Error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return newBundleError(ctxErr, ErrorTypeCanceled)
+	}
+	var bundleErr *Error
+	if errors.As(err, &bundleErr) {
+		return bundleErr
+	}
+	return newBundleError(err, errorTypeOf(err, errorType))
+}
+
+// errorTypeOf is the type of the cause err, or errorType if it isn't more specific
+func errorTypeOf(err error, errorType string) string {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return ErrorTypeCanceled
+	case errors.Is(err, ErrOutOfSpace), errors.Is(err, syscall.ENOSPC), errors.Is(err, syscall.EDQUOT):
+		return ErrorTypeOutOfSpace
+	case errors.Is(err, os.ErrPermission):
+		return ErrorTypeAccessDenied
+	case errorType == ErrorTypeSource && errors.Is(err, os.ErrNotExist):
+		// missing files while extracting are a problem of the bundle, not a missing bundle
+		return ErrorTypeNotFound
+	default:
+		return errorType
+	}
+}
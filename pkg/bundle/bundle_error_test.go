@@ -0,0 +1,52 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestError_Is_ShouldMatchSentinelOfItsTypeAndCause(t *testing.T) {
+	t.Parallel()
+	cause := errors.New("cause")
+	err := fmt.Errorf("getting bundle: %w", newBundleError(cause, ErrorTypeIntegrity))
+
+	assert.True(t, errors.Is(err, ErrIntegrity))
+	assert.False(t, errors.Is(err, ErrExtraction))
+	assert.True(t, errors.Is(err, cause))
+
+	var bundleErr *Error
+	assert.True(t, errors.As(err, &bundleErr))
+	assert.Equal(t, ErrorTypeIntegrity, bundleErr.GetErrorType())
+	assert.Equal(t, "[INTEGRITY] bundle error: cause", bundleErr.Error())
+}
+
+func TestNewBundleErrorWithContext_ShouldRefineErrorTypeWithCause(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		cause        error
+		errorType    string
+		expectedType string
+	}{
+		{errors.New("no supported Streamer"), ErrorTypeSource, ErrorTypeSource},
+		{&os.PathError{Op: "open", Path: "/bundle.tar", Err: syscall.ENOENT}, ErrorTypeSource, ErrorTypeNotFound},
+		{&os.PathError{Op: "open", Path: "/cache/file", Err: syscall.ENOENT}, ErrorTypeExtraction, ErrorTypeExtraction},
+		{&os.PathError{Op: "open", Path: "/bundle.tar", Err: syscall.EACCES}, ErrorTypeSource, ErrorTypeAccessDenied},
+		{fmt.Errorf("writing file: %w", &os.PathError{Op: "write", Path: "/cache/file", Err: syscall.ENOSPC}), ErrorTypeExtraction, ErrorTypeOutOfSpace},
+		{fmt.Errorf("store: %w", ErrOutOfSpace), ErrorTypeExtraction, ErrorTypeOutOfSpace},
+		{context.DeadlineExceeded, ErrorTypeSource, ErrorTypeCanceled},
+		{newBundleError(errors.New("mismatch"), ErrorTypeIntegrity), ErrorTypeExtraction, ErrorTypeIntegrity},
+	}
+
+	for _, testCase := range testCases {
+		err := newBundleErrorWithContext(context.Background(), testCase.cause, testCase.errorType)
+		assert.Equal(t, testCase.expectedType, err.GetErrorType(), testCase.cause.Error())
+	}
+}
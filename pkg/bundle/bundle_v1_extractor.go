@@ -5,7 +5,9 @@ package bundle
 
 import (
 	"archive/tar"
+	"context"
 	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/stream"
 	"io"
 )
 
@@ -21,18 +23,34 @@ type v1Extractor struct {
 
 	// the stream where the bundle's bytes are read from
 	readStream io.ReadSeeker
+
+	// the URL of the bundle
+	source string
+
+	// the file metadata kept while extracting
+	options ExtractOptions
 }
 
-func newBundleV1Extractor(reader io.ReadSeeker) *v1Extractor {
+func newBundleV1Extractor(reader io.ReadSeeker, source string, options ExtractOptions) *v1Extractor {
 	return &v1Extractor{
 		readStream: reader,
+		source:     source,
+		options:    options,
 	}
 }
 
+func (e *v1Extractor) Source() string {
+	return e.source
+}
+
 func (e *v1Extractor) Extract(extractLocation string, fs fs.FileSystem) error {
 	return e.extractWithTarReader(tarReaderFromStream(e.readStream), extractLocation, fs)
 }
 
+func (e *v1Extractor) ExtractWithContext(ctx context.Context, extractLocation string, fs fs.FileSystem) error {
+	return e.extractWithTarReader(tarReaderFromStream(stream.NewContextReadSeeker(ctx, e.readStream)), extractLocation, fs)
+}
+
 func (e *v1Extractor) extractWithTarReader(tarReader *tar.Reader, extractLocation string, fs fs.FileSystem) error {
 	// crete the Extract location if it doesn't exist
 	extractLocationErr := fs.MkdirAll(extractLocation, defaultFileMode)
@@ -53,7 +71,7 @@ func (e *v1Extractor) extractWithTarReader(tarReader *tar.Reader, extractLocatio
 
 		// we only Extract when they are expected files
 		if isExpectedFile(header.Name) {
-			extractErr := newTarExtractor(tarReader).Extract(extractLocation, fs)
+			extractErr := newTarExtractor(tarReader, e.options).Extract(extractLocation, fs)
 			if extractErr != nil {
 				return extractErr
 			}
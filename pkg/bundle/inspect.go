@@ -0,0 +1,115 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/stream"
+	"io"
+)
+
+// Manifest describes a bundle, as read from its metadata
+type Manifest struct {
+	// format version of the bundle
+	Version string `json:"version"`
+	// content ID of the bundle at the time it was inspected, the etag for S3 URLs
+	ContentID string `json:"contentId"`
+	// size of the bundle in bytes
+	ContentLength int64 `json:"contentLength"`
+	// overlays of a v2 bundle, in the order they are sourced, v1 bundles have none
+	Overlays []OverlayManifest `json:"overlays"`
+}
+
+// OverlayManifest describes an overlay of a v2 bundle
+type OverlayManifest struct {
+	Name   string `json:"name"`
+	Sha256 string `json:"sha256"`
+	// offset of the overlay in the bundle in bytes
+	Offset int `json:"offset"`
+	Size   int `json:"size"`
+	// whether the overlay is already extracted in the cache with the extract options of the Provider,
+	// only set by Provider.Inspect
+	Cached bool `json:"cached"`
+}
+
+// Inspect reads the manifest of the bundle pointed to by url without extracting it.
+// Only the beginning of the bundle is downloaded, but the local streamer reads the whole
+// file to compute its content ID.
+func Inspect(url string) (*Manifest, error) {
+	return InspectWithContext(context.Background(), url)
+}
+
+// InspectWithContext is the same as Inspect, it stops reading the bundle when ctx is done
+func InspectWithContext(ctx context.Context, url string) (*Manifest, error) {
+	stream, contentLength, contentID, streamErr := stream.URLToStreamWithContext(ctx, url)
+	if streamErr != nil {
+		return nil, newBundleErrorWithContext(ctx, streamErr, ErrorTypeSource)
+	}
+	defer closeStream(stream)
+
+	manifest, manifestErr := readManifest(stream)
+	if manifestErr != nil {
+		return nil, newBundleErrorWithContext(ctx, manifestErr, ErrorTypeFormat)
+	}
+	manifest.ContentID = contentID
+	manifest.ContentLength = contentLength
+	return manifest, nil
+}
+
+// Inspect is the same as the package level Inspect, and also tells which overlays are in the Provider's Cache
+func (b *Provider) Inspect(url string) (*Manifest, error) {
+	return b.InspectWithContext(context.Background(), url)
+}
+
+// InspectWithContext is the same as Inspect, it stops reading the bundle when ctx is done
+func (b *Provider) InspectWithContext(ctx context.Context, url string) (*Manifest, error) {
+	manifest, inspectErr := InspectWithContext(ctx, url)
+	if inspectErr != nil {
+		return nil, inspectErr
+	}
+
+	for i := range manifest.Overlays {
+		manifest.Overlays[i].Cached = b.bundleStore.Exists(itemKey(manifest.Overlays[i].Sha256, b.extractOptions))
+	}
+	return manifest, nil
+}
+
+func readManifest(inputStream io.ReadSeeker) (*Manifest, error) {
+	version, versionErr := readVersionFromBundle(tarReaderFromStream(inputStream))
+	if versionErr != nil {
+		return nil, fmt.Errorf("unable to read version from bundle: %v", versionErr)
+	}
+
+	manifest := &Manifest{Version: version, Overlays: []OverlayManifest{}}
+	switch version {
+	case processorVersion1:
+		return manifest, nil
+	case processorVersion2:
+		// the metadata is read from the start of the bundle again
+		if _, seekErr := inputStream.Seek(0, io.SeekStart); seekErr != nil {
+			return nil, seekErr
+		}
+		metadataTarReader, metadataErr := getMetadataTarReader(inputStream)
+		if metadataErr != nil {
+			return nil, metadataErr
+		}
+		overlays, overlaysErr := getOverlays(metadataTarReader)
+		if overlaysErr != nil {
+			return nil, overlaysErr
+		}
+
+		for _, overlay := range overlays.Overlays {
+			manifest.Overlays = append(manifest.Overlays, OverlayManifest{
+				Name:   overlay.FileName,
+				Sha256: overlay.Sha256,
+				Offset: overlay.Offset,
+				Size:   overlay.Size,
+			})
+		}
+		return manifest, nil
+	default:
+		return nil, newBundleError(fmt.Errorf("unsupported bundle processor version: %s", version), ErrorTypeUnsupportedVersion)
+	}
+}
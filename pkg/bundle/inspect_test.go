@@ -0,0 +1,175 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/stream"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/stream/local"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func init() {
+	stream.RegisterStreamer(local.NewStreamer())
+	stream.RegisterStreamer(testClosingStreamer)
+}
+
+// testClosingStreamer streams the local file at the path of closetest:// URLs, and counts the streams left open by URL
+var testClosingStreamer = &closingStreamer{openStreams: make(map[string]int)}
+
+type closingStreamer struct {
+	mutex       sync.Mutex
+	openStreams map[string]int
+}
+
+func (s *closingStreamer) CanStream(url string) bool {
+	return strings.HasPrefix(url, closingStreamerScheme)
+}
+
+func (s *closingStreamer) CreateStream(url string) (io.ReadSeeker, int64, string, error) {
+	content, readErr := ioutil.ReadFile(strings.TrimPrefix(url, closingStreamerScheme))
+	if readErr != nil {
+		return nil, 0, "", readErr
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.openStreams[url]++
+	return &closingStream{Reader: bytes.NewReader(content), close: func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		s.openStreams[url]--
+	}}, int64(len(content)), "etag", nil
+}
+
+func (s *closingStreamer) openStreamsOf(url string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.openStreams[url]
+}
+
+const closingStreamerScheme = "closetest://"
+
+type closingStream struct {
+	*bytes.Reader
+	close func()
+}
+
+func (s *closingStream) Close() error {
+	s.close()
+	return nil
+}
+
+// writes a v2 bundle of a single tarball overlay into a temporary directory
+func createTestBundle(t *testing.T) (string, []byte) {
+	tempDir, _ := ioutil.TempDir("", "inspect")
+	overlay := createTestOverlay(t)
+	overlayPath := filepath.Join(tempDir, overlayFileName)
+	ioutil.WriteFile(overlayPath, overlay, 0644)
+
+	bundlePath := filepath.Join(tempDir, "bundle.tar")
+	assert.Nil(t, NewWriter().Make(bundlePath, []string{overlayPath}))
+	return bundlePath, overlay
+}
+
+// creates a tar holding a version file and nothing else
+func createVersionOnlyBundle(t *testing.T, version string) []byte {
+	var buf bytes.Buffer
+	tarWriter := tar.NewWriter(&buf)
+	assert.Nil(t, writeTarEntry(tarWriter, newFileHeader(versionFileName, int64(len(version))), bytes.NewReader([]byte(version))))
+	assert.Nil(t, tarWriter.Close())
+	return buf.Bytes()
+}
+
+func TestInspect_WithV2Bundle_ShouldReturnOverlays(t *testing.T) {
+	t.Parallel()
+	bundlePath, overlay := createTestBundle(t)
+	defer os.RemoveAll(filepath.Dir(bundlePath))
+
+	manifest, err := Inspect(bundlePath)
+
+	assert.Nil(t, err)
+	assert.Equal(t, processorVersion2, manifest.Version)
+	assert.NotEmpty(t, manifest.ContentID)
+	info, _ := os.Stat(bundlePath)
+	assert.Equal(t, info.Size(), manifest.ContentLength)
+	assert.Equal(t, 1, len(manifest.Overlays))
+	assert.Equal(t, overlayFileName, manifest.Overlays[0].Name)
+	assert.Equal(t, sha256Hex(overlay), manifest.Overlays[0].Sha256)
+	assert.Equal(t, len(overlay), manifest.Overlays[0].Size)
+	assert.False(t, manifest.Overlays[0].Cached)
+
+	// the overlay is at its offset
+	bundleBytes, _ := ioutil.ReadFile(bundlePath)
+	offset := manifest.Overlays[0].Offset
+	assert.Equal(t, overlay, bundleBytes[offset:offset+len(overlay)])
+}
+
+func TestInspect_WithRemoteBundle_ShouldCloseStream(t *testing.T) {
+	t.Parallel()
+	bundlePath, _ := createTestBundle(t)
+	defer os.RemoveAll(filepath.Dir(bundlePath))
+	url := closingStreamerScheme + bundlePath
+
+	manifest, err := Inspect(url)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "etag", manifest.ContentID)
+	assert.Equal(t, 0, testClosingStreamer.openStreamsOf(url))
+}
+
+func TestProvider_Inspect_ShouldMarkCachedOverlays(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bundlePath, overlay := createTestBundle(t)
+	defer os.RemoveAll(filepath.Dir(bundlePath))
+
+	mockBundleStore := NewMockCache(ctrl)
+	mockBundleStore.EXPECT().Exists(sha256Hex(overlay)).Return(true)
+
+	manifest, err := NewProvider(mockBundleStore).Inspect(bundlePath)
+
+	assert.Nil(t, err)
+	assert.True(t, manifest.Overlays[0].Cached)
+}
+
+func TestInspect_WithMissingBundle_ShouldReturnNotFoundError(t *testing.T) {
+	t.Parallel()
+	manifest, err := Inspect("/missing/bundle.tar")
+
+	assert.Nil(t, manifest)
+	bundleErr, ok := err.(*Error)
+	assert.True(t, ok)
+	assert.Equal(t, ErrorTypeNotFound, bundleErr.GetErrorType())
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestReadManifest_WithV1Bundle_ShouldReturnNoOverlays(t *testing.T) {
+	t.Parallel()
+	manifest, err := readManifest(bytes.NewReader(createVersionOnlyBundle(t, processorVersion1)))
+
+	assert.Nil(t, err)
+	assert.Equal(t, processorVersion1, manifest.Version)
+	assert.Empty(t, manifest.Overlays)
+}
+
+func TestReadManifest_WithUnsupportedVersion_ShouldReturnError(t *testing.T) {
+	t.Parallel()
+	manifest, err := readManifest(bytes.NewReader(createVersionOnlyBundle(t, "3")))
+
+	assert.Nil(t, manifest)
+	assert.True(t, errors.Is(err, ErrUnsupportedVersion))
+}
@@ -0,0 +1,187 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/3p/archiver"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/stream"
+	"github.com/google/uuid"
+	"hash"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// integrityExtractor hashes the bytes of an overlay while they are being extracted
+// and fails the extraction if they don't match the overlay's expected sha256.
+type integrityExtractor struct {
+	extractor Extractor
+
+	// the reader the wrapped extractor consumes, every byte read through it is hashed
+	readStream     io.Reader
+	hash           hash.Hash
+	expectedSha256 string
+
+	// the URL of the bundle the overlay is part of
+	source string
+
+	// the overlay's file name, and where its phases are reported, nil when progress isn't reported
+	fileName string
+	progress *progressTracker
+
+	// set for archive formats read out of order, the overlay is then hashed before it is extracted
+	spool        bool
+	spoolOptions ExtractOptions
+}
+
+// the mode of extraction locations created to hold a spooled overlay
+const spoolDirectoryMode fs.FileMode = 0755
+
+// newIntegrityExtractor returns nil if there is no extractor for fileName
+func newIntegrityExtractor(reader io.Reader, fileName string, expectedSha256 string, source string, options ExtractOptions) *integrityExtractor {
+	hash := sha256.New()
+
+	// bytes can't be hashed in order while a zip is extracted, the overlay is hashed before instead
+	if archiver.MatchingFormat(fileName) == archiver.Zip {
+		return &integrityExtractor{
+			readStream:     reader,
+			hash:           hash,
+			expectedSha256: expectedSha256,
+			source:         source,
+			fileName:       fileName,
+			spool:          true,
+			spoolOptions:   options,
+		}
+	}
+
+	teeReader := io.TeeReader(reader, hash)
+
+	extractor := extractorFromFileName(teeReader, fileName, options)
+	if extractor == nil {
+		return nil
+	}
+
+	return &integrityExtractor{
+		extractor:      extractor,
+		readStream:     teeReader,
+		hash:           hash,
+		expectedSha256: expectedSha256,
+		source:         source,
+		fileName:       fileName,
+	}
+}
+
+func (e *integrityExtractor) Source() string {
+	return e.source
+}
+
+func (e *integrityExtractor) Extract(extractLocation string, fs fs.FileSystem) error {
+	return e.ExtractWithContext(context.Background(), extractLocation, fs)
+}
+
+func (e *integrityExtractor) ExtractWithContext(ctx context.Context, extractLocation string, fs fs.FileSystem) error {
+	if e.spool {
+		return e.verifyThenExtract(ctx, extractLocation, fs)
+	}
+	e.progress.setPhase(ProgressPhaseExtract, e.fileName)
+
+	extractErr := extractWithContext(ctx, e.extractor, extractLocation, fs)
+
+	// archivers stop reading at the end of the archive, which can be before the end of the overlay
+	// (tar padding, gzip trailers), so we consume the rest to hash the whole overlay
+	_, drainErr := io.Copy(ioutil.Discard, stream.NewContextReader(ctx, e.readStream))
+
+	// a corrupted overlay usually also fails to extract, report it as an integrity problem
+	if drainErr == nil {
+		if verifyErr := e.verify(); verifyErr != nil {
+			return verifyErr
+		}
+	}
+
+	if extractErr != nil {
+		return extractErr
+	}
+	return drainErr
+}
+
+// verifyThenExtract hashes the whole overlay, and extracts it once it is verified. An overlay which can be
+// read at any offset, like the section of a local bundle, is extracted in place. Others could give other
+// bytes than the ones verified when read a second time: they are copied while they are hashed to a spool
+// file in extractLocation on fileSystem, which is extracted instead.
+func (e *integrityExtractor) verifyThenExtract(ctx context.Context, extractLocation string, fileSystem fs.FileSystem) error {
+	e.progress.setPhase(ProgressPhaseVerify, e.fileName)
+	if readerAt, isReaderAt := e.readStream.(readSeekerAt); isReaderAt {
+		return e.verifyThenExtractInPlace(ctx, readerAt, extractLocation, fileSystem)
+	}
+
+	if mkdirErr := fileSystem.MkdirAll(extractLocation, spoolDirectoryMode); mkdirErr != nil {
+		return mkdirErr
+	}
+	// the name is unknown to the overlay, so none of its files replaces the spool
+	spoolPath := filepath.Join(extractLocation, ".overlay-"+uuid.New().String()+".zip")
+	spoolFile, createErr := fileSystem.Create(spoolPath)
+	if createErr != nil {
+		return createErr
+	}
+	defer func() {
+		spoolFile.Close()
+		fileSystem.Remove(spoolPath)
+	}()
+
+	if _, copyErr := io.Copy(io.MultiWriter(spoolFile, e.hash), stream.NewContextReader(ctx, e.readStream)); copyErr != nil {
+		return copyErr
+	}
+	if verifyErr := e.verify(); verifyErr != nil {
+		return verifyErr
+	}
+
+	if _, seekErr := spoolFile.Seek(0, io.SeekStart); seekErr != nil {
+		return seekErr
+	}
+	e.progress.setPhase(ProgressPhaseExtract, e.fileName)
+	return extractWithContext(ctx, newExtractor(spoolFile, archiver.Zip, e.spoolOptions), extractLocation, fileSystem)
+}
+
+// verifyThenExtractInPlace hashes the overlay read at offsets of overlay, then extracts it from overlay
+func (e *integrityExtractor) verifyThenExtractInPlace(ctx context.Context, overlay readSeekerAt, extractLocation string, fileSystem fs.FileSystem) error {
+	size, seekErr := overlay.Seek(0, io.SeekEnd)
+	if seekErr != nil {
+		return seekErr
+	}
+
+	// the overlay is read a second time to be extracted
+	e.progress.expect(size)
+	if _, hashErr := io.Copy(e.hash, stream.NewContextReader(ctx, io.NewSectionReader(overlay, 0, size))); hashErr != nil {
+		return hashErr
+	}
+	if verifyErr := e.verify(); verifyErr != nil {
+		return verifyErr
+	}
+
+	if _, seekErr := overlay.Seek(0, io.SeekStart); seekErr != nil {
+		return seekErr
+	}
+	e.progress.setPhase(ProgressPhaseExtract, e.fileName)
+	return extractWithContext(ctx, newExtractor(overlay, archiver.Zip, e.spoolOptions), extractLocation, fileSystem)
+}
+
+// readSeekerAt is an overlay which can be read at any offset
+type readSeekerAt interface {
+	io.ReadSeeker
+	io.ReaderAt
+}
+
+func (e *integrityExtractor) verify() error {
+	actualSha256 := hex.EncodeToString(e.hash.Sum(nil))
+	if !strings.EqualFold(actualSha256, e.expectedSha256) {
+		return newBundleError(fmt.Errorf("overlay sha256 [%v] does not match expected sha256 [%v]", actualSha256, e.expectedSha256), ErrorTypeIntegrity)
+	}
+	return nil
+}
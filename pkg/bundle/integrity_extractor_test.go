@@ -0,0 +1,212 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	overlayFileName    = "overlay.tar.gz"
+	overlayFileContent = "source me"
+)
+
+// creates a tar.gz overlay containing a single setup.sh file
+func createTestOverlay(t *testing.T) []byte {
+	var buf bytes.Buffer
+	gzWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzWriter)
+	assert.Nil(t, tarWriter.WriteHeader(&tar.Header{
+		Name:     "setup.sh",
+		Mode:     0644,
+		Size:     int64(len(overlayFileContent)),
+		Typeflag: tar.TypeReg,
+	}))
+	_, writeErr := tarWriter.Write([]byte(overlayFileContent))
+	assert.Nil(t, writeErr)
+	assert.Nil(t, tarWriter.Close())
+	assert.Nil(t, gzWriter.Close())
+	return buf.Bytes()
+}
+
+// creates a zip overlay containing a single setup.sh file
+func createTestZipOverlay(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	fileWriter, createErr := zipWriter.Create("setup.sh")
+	assert.Nil(t, createErr)
+	_, writeErr := fileWriter.Write([]byte(overlayFileContent))
+	assert.Nil(t, writeErr)
+	assert.Nil(t, zipWriter.Close())
+	return buf.Bytes()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestIntegrityExtractor_Extract_WithMatchingSha256_ShouldExtract(t *testing.T) {
+	t.Parallel()
+	extractLocation, _ := ioutil.TempDir("", "integrity")
+	defer os.RemoveAll(extractLocation)
+
+	overlay := createTestOverlay(t)
+
+	extractor := newIntegrityExtractor(bytes.NewReader(overlay), overlayFileName, sha256Hex(overlay), "", ExtractOptions{})
+	extractErr := extractor.Extract(extractLocation, fs.NewLocalFS())
+
+	assert.Nil(t, extractErr)
+	content, _ := ioutil.ReadFile(filepath.Join(extractLocation, "setup.sh"))
+	assert.Equal(t, overlayFileContent, string(content))
+}
+
+func TestIntegrityExtractor_Extract_WithMismatchingSha256_ShouldReturnIntegrityError(t *testing.T) {
+	t.Parallel()
+	extractLocation, _ := ioutil.TempDir("", "integrity")
+	defer os.RemoveAll(extractLocation)
+
+	overlay := createTestOverlay(t)
+
+	extractor := newIntegrityExtractor(bytes.NewReader(overlay), overlayFileName, sha256Hex([]byte("tampered")), "", ExtractOptions{})
+	extractErr := extractor.Extract(extractLocation, fs.NewLocalFS())
+
+	assert.NotNil(t, extractErr)
+	bundleErr, ok := extractErr.(*Error)
+	assert.True(t, ok)
+	assert.Equal(t, ErrorTypeIntegrity, bundleErr.GetErrorType())
+}
+
+func TestIntegrityExtractor_Extract_WithCorruptedOverlay_ShouldReturnIntegrityError(t *testing.T) {
+	t.Parallel()
+	extractLocation, _ := ioutil.TempDir("", "integrity")
+	defer os.RemoveAll(extractLocation)
+
+	overlay := createTestOverlay(t)
+	expectedSha256 := sha256Hex(overlay)
+	overlay[len(overlay)/2] ^= 0xff
+
+	extractor := newIntegrityExtractor(bytes.NewReader(overlay), overlayFileName, expectedSha256, "", ExtractOptions{})
+	extractErr := extractor.Extract(extractLocation, fs.NewLocalFS())
+
+	bundleErr, ok := extractErr.(*Error)
+	assert.True(t, ok)
+	assert.Equal(t, ErrorTypeIntegrity, bundleErr.GetErrorType())
+}
+
+func TestNewIntegrityExtractor_WithUnknownFormat_ShouldReturnNil(t *testing.T) {
+	t.Parallel()
+	assert.Nil(t, newIntegrityExtractor(bytes.NewReader(nil), "overlay.unknown", "", "", ExtractOptions{}))
+}
+
+func TestIntegrityExtractor_ExtractWithContext_WhenCancelled_ShouldReturnError(t *testing.T) {
+	t.Parallel()
+	extractLocation, _ := ioutil.TempDir("", "integrity")
+	defer os.RemoveAll(extractLocation)
+
+	overlay := createTestOverlay(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	extractor := newIntegrityExtractor(bytes.NewReader(overlay), overlayFileName, sha256Hex(overlay), "", ExtractOptions{})
+	extractErr := extractor.ExtractWithContext(ctx, extractLocation, fs.NewLocalFS())
+
+	assert.NotNil(t, extractErr)
+	_, statErr := os.Stat(filepath.Join(extractLocation, "setup.sh"))
+	assert.True(t, os.IsNotExist(statErr))
+}
+
+func TestIntegrityExtractor_Extract_WithZipOverlay_ShouldExtract(t *testing.T) {
+	t.Parallel()
+	extractLocation, _ := ioutil.TempDir("", "integrity")
+	defer os.RemoveAll(extractLocation)
+
+	overlay := createTestZipOverlay(t)
+
+	extractor := newIntegrityExtractor(bytes.NewReader(overlay), "overlay.zip", sha256Hex(overlay), "", ExtractOptions{})
+	extractErr := extractor.Extract(extractLocation, fs.NewLocalFS())
+
+	assert.Nil(t, extractErr)
+	content, _ := ioutil.ReadFile(filepath.Join(extractLocation, "setup.sh"))
+	assert.Equal(t, overlayFileContent, string(content))
+}
+
+// changingReadSeeker serves other once it is seeked, like a remote overlay replaced between two reads
+type changingReadSeeker struct {
+	reader *bytes.Reader
+	other  []byte
+}
+
+func (r *changingReadSeeker) Read(p []byte) (int, error) {
+	return r.reader.Read(p)
+}
+
+func (r *changingReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	r.reader = bytes.NewReader(r.other)
+	return r.reader.Seek(offset, whence)
+}
+
+func TestIntegrityExtractor_Extract_WithZipOverlayChangingOnReread_ShouldExtractVerifiedBytes(t *testing.T) {
+	t.Parallel()
+	extractLocation, _ := ioutil.TempDir("", "integrity")
+	defer os.RemoveAll(extractLocation)
+
+	overlay := createTestZipOverlay(t)
+	reader := &changingReadSeeker{reader: bytes.NewReader(overlay), other: []byte("tampered")}
+
+	extractor := newIntegrityExtractor(reader, "overlay.zip", sha256Hex(overlay), "", ExtractOptions{})
+	extractErr := extractor.Extract(extractLocation, fs.NewLocalFS())
+
+	assert.Nil(t, extractErr)
+	content, _ := ioutil.ReadFile(filepath.Join(extractLocation, "setup.sh"))
+	assert.Equal(t, overlayFileContent, string(content))
+}
+
+func TestIntegrityExtractor_Extract_WithRemoteZipOverlay_ShouldSpoolOnFileSystem(t *testing.T) {
+	t.Parallel()
+	memFS := fs.NewMemFS()
+
+	overlay := createTestZipOverlay(t)
+	reader := &changingReadSeeker{reader: bytes.NewReader(overlay), other: overlay}
+
+	extractor := newIntegrityExtractor(reader, "overlay.zip", sha256Hex(overlay), "", ExtractOptions{})
+	extractErr := extractor.Extract("/overlay", memFS)
+
+	assert.Nil(t, extractErr)
+	content, _ := memFS.ReadFile("/overlay/setup.sh")
+	assert.Equal(t, overlayFileContent, string(content))
+	// the spool is removed once the overlay is extracted
+	files, _ := memFS.ReadDir("/overlay")
+	assert.Equal(t, 1, len(files))
+}
+
+func TestIntegrityExtractor_Extract_WithMismatchingZipOverlay_ShouldNotExtract(t *testing.T) {
+	t.Parallel()
+	extractLocation, _ := ioutil.TempDir("", "integrity")
+	defer os.RemoveAll(extractLocation)
+
+	overlay := createTestZipOverlay(t)
+
+	extractor := newIntegrityExtractor(bytes.NewReader(overlay), "overlay.zip", sha256Hex([]byte("tampered")), "", ExtractOptions{})
+	extractErr := extractor.Extract(extractLocation, fs.NewLocalFS())
+
+	bundleErr, ok := extractErr.(*Error)
+	assert.True(t, ok)
+	assert.Equal(t, ErrorTypeIntegrity, bundleErr.GetErrorType())
+	// zip overlays are verified before they are extracted
+	_, statErr := os.Stat(filepath.Join(extractLocation, "setup.sh"))
+	assert.True(t, os.IsNotExist(statErr))
+}
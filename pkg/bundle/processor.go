@@ -4,7 +4,10 @@
 package bundle
 
 import (
+	"context"
 	"io"
+	"log/slog"
+	"strings"
 )
 
 const (
@@ -16,16 +19,49 @@ const (
 // This includes the knowledge on how to process v1, v2, etc.
 type bundleProcessor interface {
 	// Extract takes the bundle bytes and extracts everything into the bundle store
-	extract(inputStream io.ReadSeeker, bundleCache Cache) (Bundle, error)
+	// source is the URL of the bundle, it is passed on to the store with the extractors
+	// contentID is the content ID of the bundle given by its streamer, empty if unknown
+	// the items are released if ctx is done before all of them are extracted
+	// options is the file metadata kept while extracting
+	extract(ctx context.Context, inputStream io.ReadSeeker, bundleCache Cache, source string, contentID string, options ExtractOptions) (Bundle, error)
 }
 
-func processorForVersion(version string) bundleProcessor {
+// processorConfig sets up the processors of bundles
+type processorConfig struct {
+	// records the progress of the processor, nil to discard the records
+	logger *slog.Logger
+
+	// maximum number of overlays extracted at the same time, they are extracted one after another below 2
+	workers int
+}
+
+func processorForVersion(version string, config processorConfig) bundleProcessor {
 	switch version {
 	case processorVersion1:
 		return newBundleProcessorV1()
 	case processorVersion2:
-		return newBundleProcessorV2()
+		return newBundleProcessorV2(config)
 	default:
 		return nil
 	}
 }
+
+// itemKey is the key of the item holding content extracted with options. The metadata kept by the
+// preserve options changes the extracted files, so each combination of them is its own item.
+// Items extracted with the default options are keyed by contentKey alone.
+func itemKey(contentKey string, options ExtractOptions) string {
+	var preserved []string
+	if options.PreserveModTimes {
+		preserved = append(preserved, "modtimes")
+	}
+	if options.PreserveOwnership {
+		preserved = append(preserved, "ownership")
+	}
+	if options.PreserveXattrs {
+		preserved = append(preserved, "xattrs")
+	}
+	if len(preserved) == 0 {
+		return contentKey
+	}
+	return contentKey + "-preserve-" + strings.Join(preserved, "-")
+}
@@ -10,7 +10,7 @@ import (
 
 func TestBundleProcessorForVersion_V1_ShouldReturnV1(t *testing.T) {
 	t.Parallel()
-	processor := processorForVersion(processorVersion1)
+	processor := processorForVersion(processorVersion1, processorConfig{})
 
 	// type assert that this is v1
 	_, ok := processor.(*bundleProcessorV1)
@@ -21,7 +21,7 @@ func TestBundleProcessorForVersion_V1_ShouldReturnV1(t *testing.T) {
 
 func TestBundleProcessorForVersion_V2_ShouldReturnV2(t *testing.T) {
 	t.Parallel()
-	processor := processorForVersion(processorVersion2)
+	processor := processorForVersion(processorVersion2, processorConfig{})
 
 	// type assert that this is v2
 	_, ok := processor.(*bundleProcessorV2)
@@ -32,7 +32,20 @@ func TestBundleProcessorForVersion_V2_ShouldReturnV2(t *testing.T) {
 
 func TestBundleProcessorForVersion_Unsupported_ShouldReturnNil(t *testing.T) {
 	t.Parallel()
-	processor := processorForVersion("NoVersion")
+	processor := processorForVersion("NoVersion", processorConfig{})
 
 	assert.Nil(t, processor)
 }
+
+func TestItemKey_WithPreserveOptions_ShouldKeyEachCombination(t *testing.T) {
+	t.Parallel()
+	// the items extracted before preserve options existed keep their keys
+	assert.Equal(t, "content", itemKey("content", ExtractOptions{MaxBytes: 1}))
+	assert.Equal(t, "content-preserve-modtimes", itemKey("content", ExtractOptions{PreserveModTimes: true}))
+	assert.Equal(t, "content-preserve-modtimes-ownership-xattrs", itemKey("content", ExtractOptions{
+		PreserveModTimes:  true,
+		PreserveOwnership: true,
+		PreserveXattrs:    true,
+	}))
+	assert.NotEqual(t, itemKey("content", ExtractOptions{PreserveOwnership: true}), itemKey("content", ExtractOptions{PreserveXattrs: true}))
+}
@@ -0,0 +1,249 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// ProgressPhase is the step a bundle is going through
+type ProgressPhase string
+
+const (
+	// the bundle is read up to its overlays
+	ProgressPhaseDownload ProgressPhase = "download"
+	// the bundle, or an overlay, is read to be hashed before it is extracted
+	ProgressPhaseVerify ProgressPhase = "verify"
+	// the bundle, or an overlay, is extracted
+	ProgressPhaseExtract ProgressPhase = "extract"
+)
+
+// ProgressEvent describes the progress of the download and extraction of a bundle
+type ProgressEvent struct {
+	Phase ProgressPhase
+
+	// file name of the last overlay whose processing started, empty for v1 bundles
+	Overlay string
+
+	// bytes read so far out of the bytes to read. BytesRead never decreases, even when the bundle
+	// is read out of order. Overlays already in the Cache aren't read and are not counted in TotalBytes,
+	// and parts of the bundle read twice to be verified then extracted count twice.
+	BytesRead  int64
+	TotalBytes int64
+
+	Elapsed time.Duration
+
+	// average bytes read per second so far
+	BytesPerSecond float64
+
+	// estimated time left at the average throughput, 0 when unknown
+	ETA time.Duration
+
+	// set on the last event, once the bundle is extracted
+	Done bool
+}
+
+// PercentDone is BytesRead in percent of TotalBytes
+func (e ProgressEvent) PercentDone() float32 {
+	if e.TotalBytes <= 0 {
+		if e.Done {
+			return 100
+		}
+		return 0
+	}
+	return float32((float64(e.BytesRead) / float64(e.TotalBytes)) * 100)
+}
+
+// ProgressObserver is notified of the progress of the bundles a Provider gets.
+// The calls for a bundle are never concurrent, reading the bundle waits for them to return.
+type ProgressObserver interface {
+	OnProgress(event ProgressEvent)
+}
+
+// ProgressObserverFunc is a function used as a ProgressObserver
+type ProgressObserverFunc func(event ProgressEvent)
+
+func (f ProgressObserverFunc) OnProgress(event ProgressEvent) {
+	f(event)
+}
+
+// progressTracker counts the bytes read from a bundle and notifies its observer at most once
+// per rate, and whenever the phase or the overlay changes. Its methods do nothing on a nil tracker.
+type progressTracker struct {
+	mutex        sync.Mutex
+	observer     ProgressObserver
+	rate         time.Duration
+	startTime    time.Time
+	lastNotified time.Time
+
+	bytesRead  int64
+	totalBytes int64
+	phase      ProgressPhase
+	overlay    string
+}
+
+func newProgressTracker(observer ProgressObserver, rate time.Duration, totalBytes int64) *progressTracker {
+	now := time.Now()
+	return &progressTracker{
+		observer:     observer,
+		rate:         rate,
+		startTime:    now,
+		lastNotified: now,
+		totalBytes:   totalBytes,
+		phase:        ProgressPhaseDownload,
+	}
+}
+
+// read counts n more bytes read
+func (p *progressTracker) read(n int) {
+	if p == nil || n <= 0 {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.bytesRead += int64(n)
+	reachedTotal := p.bytesRead >= p.totalBytes && p.bytesRead-int64(n) < p.totalBytes
+	if now := time.Now(); now.Sub(p.lastNotified) > p.rate || reachedTotal {
+		p.notify(now, false)
+	}
+}
+
+// skip leaves size bytes which won't be read out of the bytes to read
+func (p *progressTracker) skip(size int64) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.totalBytes -= size
+}
+
+// expect adds size bytes which will be read once more to the bytes to read
+func (p *progressTracker) expect(size int64) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.totalBytes += size
+}
+
+// expectAgain adds the bytes of the whole bundle to the bytes to read, for bundles read twice
+func (p *progressTracker) expectAgain() {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.totalBytes *= 2
+}
+
+// setPhase notifies the observer when phase or overlay changes
+func (p *progressTracker) setPhase(phase ProgressPhase, overlay string) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if phase == p.phase && overlay == p.overlay {
+		return
+	}
+	p.phase = phase
+	p.overlay = overlay
+	p.notify(time.Now(), false)
+}
+
+// finish notifies the observer that the bundle is extracted
+func (p *progressTracker) finish() {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.bytesRead < p.totalBytes {
+		p.bytesRead = p.totalBytes
+	}
+	p.notify(time.Now(), true)
+}
+
+// notify must be called with the mutex held, so that events are delivered in order
+func (p *progressTracker) notify(now time.Time, done bool) {
+	p.lastNotified = now
+
+	// bytes read more than expected, such as headers read again, don't go past the total
+	event := ProgressEvent{
+		Phase:      p.phase,
+		Overlay:    p.overlay,
+		BytesRead:  p.bytesRead,
+		TotalBytes: p.totalBytes,
+		Elapsed:    now.Sub(p.startTime),
+		Done:       done,
+	}
+	if event.BytesRead > event.TotalBytes {
+		event.BytesRead = event.TotalBytes
+	}
+	if seconds := event.Elapsed.Seconds(); seconds > 0 {
+		event.BytesPerSecond = float64(event.BytesRead) / seconds
+	}
+	if event.BytesPerSecond > 0 {
+		event.ETA = time.Duration(float64(event.TotalBytes-event.BytesRead) / event.BytesPerSecond * float64(time.Second))
+	}
+	p.observer.OnProgress(event)
+}
+
+// progressReadSeeker counts the bytes read from r in its progress
+type progressReadSeeker struct {
+	r        io.ReadSeeker
+	progress *progressTracker
+}
+
+// newProgressReadSeeker counts the bytes read from r in progress. When r is also an io.ReaderAt,
+// so is the returned reader. Returns r when progress is nil.
+func newProgressReadSeeker(r io.ReadSeeker, progress *progressTracker) io.ReadSeeker {
+	if progress == nil {
+		return r
+	}
+	if readerAt, ok := r.(io.ReaderAt); ok {
+		return &progressReadSeekerAt{progressReadSeeker: progressReadSeeker{r: r, progress: progress}, readerAt: readerAt}
+	}
+	return &progressReadSeeker{r: r, progress: progress}
+}
+
+func (r *progressReadSeeker) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.progress.read(n)
+	return n, err
+}
+
+func (r *progressReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return r.r.Seek(offset, whence)
+}
+
+type progressReadSeekerAt struct {
+	progressReadSeeker
+	readerAt io.ReaderAt
+}
+
+func (r *progressReadSeekerAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.readerAt.ReadAt(p, off)
+	r.progress.read(n)
+	return n, err
+}
+
+// progressOf returns the progress bytes read from inputStream are counted in, nil if they aren't
+func progressOf(inputStream io.ReadSeeker) *progressTracker {
+	switch r := inputStream.(type) {
+	case *progressReadSeeker:
+		return r.progress
+	case *progressReadSeekerAt:
+		return r.progress
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// recordEvents returns a tracker of totalBytes which records the events it notifies
+func recordEvents(rate time.Duration, totalBytes int64) (*progressTracker, *[]ProgressEvent) {
+	var events []ProgressEvent
+	progress := newProgressTracker(ProgressObserverFunc(func(event ProgressEvent) {
+		events = append(events, event)
+	}), rate, totalBytes)
+	return progress, &events
+}
+
+func TestProgressEvent_PercentDone(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, float32(25), ProgressEvent{BytesRead: 1, TotalBytes: 4}.PercentDone())
+	assert.Equal(t, float32(0), ProgressEvent{}.PercentDone())
+	assert.Equal(t, float32(100), ProgressEvent{Done: true}.PercentDone())
+}
+
+func TestProgressReadSeeker_WhenRereadAfterSeek_ShouldNotDecreaseBytesRead(t *testing.T) {
+	t.Parallel()
+	progress, events := recordEvents(0, 8)
+	r := newProgressReadSeeker(bytes.NewReader([]byte("abcdefgh")), progress)
+
+	buffer := make([]byte, 4)
+	r.Read(buffer)
+	r.Seek(0, io.SeekStart)
+	r.Read(buffer)
+	r.Seek(4, io.SeekStart)
+	r.Read(buffer)
+
+	var lastBytesRead int64
+	for _, event := range *events {
+		assert.True(t, event.BytesRead >= lastBytesRead)
+		assert.True(t, event.BytesRead <= event.TotalBytes)
+		lastBytesRead = event.BytesRead
+	}
+	assert.Equal(t, int64(8), lastBytesRead)
+}
+
+func TestProgressReadSeeker_WithReaderAt_ShouldCountReadAt(t *testing.T) {
+	t.Parallel()
+	progress, _ := recordEvents(time.Hour, 8)
+	r := newProgressReadSeeker(bytes.NewReader([]byte("abcdefgh")), progress)
+
+	readerAt, ok := r.(io.ReaderAt)
+	assert.True(t, ok)
+	readerAt.ReadAt(make([]byte, 3), 5)
+	assert.Equal(t, int64(3), progress.bytesRead)
+	assert.Equal(t, progress, progressOf(r))
+}
+
+func TestProgressReadSeeker_WithoutProgress_ShouldReturnReader(t *testing.T) {
+	t.Parallel()
+	reader := bytes.NewReader([]byte("abcdefgh"))
+
+	r := newProgressReadSeeker(reader, nil)
+
+	assert.Equal(t, reader, r)
+	assert.Nil(t, progressOf(r))
+}
+
+func TestProgressTracker_WhenSkipped_ShouldReachTotalWithoutSkippedBytes(t *testing.T) {
+	t.Parallel()
+	progress, events := recordEvents(time.Hour, 8)
+	progress.skip(4)
+
+	ioutil.ReadAll(io.LimitReader(newProgressReadSeeker(bytes.NewReader([]byte("abcdefgh")), progress), 4))
+
+	// reaching the total is notified regardless of the rate
+	assert.Equal(t, 1, len(*events))
+	assert.Equal(t, float32(100), (*events)[0].PercentDone())
+	assert.Equal(t, int64(4), (*events)[0].TotalBytes)
+}
+
+func TestProgressTracker_ShouldNotifyPhasesOverlaysAndDone(t *testing.T) {
+	t.Parallel()
+	progress, events := recordEvents(time.Hour, 8)
+
+	progress.setPhase(ProgressPhaseVerify, "overlay1.zip")
+	progress.setPhase(ProgressPhaseVerify, "overlay1.zip")
+	progress.setPhase(ProgressPhaseExtract, "overlay1.zip")
+	progress.setPhase(ProgressPhaseExtract, "overlay2.tar")
+	progress.finish()
+
+	assert.Equal(t, 4, len(*events))
+	assert.Equal(t, ProgressPhaseVerify, (*events)[0].Phase)
+	assert.Equal(t, "overlay1.zip", (*events)[0].Overlay)
+	assert.Equal(t, ProgressPhaseExtract, (*events)[1].Phase)
+	assert.Equal(t, "overlay2.tar", (*events)[2].Overlay)
+	assert.False(t, (*events)[2].Done)
+	assert.True(t, (*events)[3].Done)
+	assert.Equal(t, int64(8), (*events)[3].BytesRead)
+	assert.Equal(t, time.Duration(0), (*events)[3].ETA)
+}
+
+func TestProgressTracker_WhenNil_ShouldDoNothing(t *testing.T) {
+	t.Parallel()
+	var progress *progressTracker
+
+	progress.read(1)
+	progress.skip(1)
+	progress.expect(1)
+	progress.expectAgain()
+	progress.setPhase(ProgressPhaseExtract, "")
+	progress.finish()
+}
+
+func TestProvider_Observer_ShouldNotifyObserverAndCallback(t *testing.T) {
+	t.Parallel()
+	provider := NewProvider(nil)
+	assert.Nil(t, provider.observer())
+
+	var percents []float32
+	var events []ProgressEvent
+	provider.SetProgressCallback(func(percentDone float32, timeElapsed time.Duration) {
+		percents = append(percents, percentDone)
+	})
+	provider.SetProgressObserver(ProgressObserverFunc(func(event ProgressEvent) {
+		events = append(events, event)
+	}))
+
+	provider.observer().OnProgress(ProgressEvent{BytesRead: 1, TotalBytes: 2})
+
+	assert.Equal(t, []float32{50}, percents)
+	assert.Equal(t, 1, len(events))
+}
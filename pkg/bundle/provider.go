@@ -7,9 +7,16 @@ package bundle
 //go:generate mockgen -destination=mock_extractor.go -self_package=github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/bundle -package=bundle github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/bundle Extractor
 
 import (
+	"context"
 	"fmt"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/3p/archiver"
 	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/logging"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/metrics"
 	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/stream"
+	"io"
+	"log/slog"
+	"runtime"
 	"time"
 )
 
@@ -57,12 +64,45 @@ type Cache interface {
 	Cleanup()
 }
 
+// ContextCache is a Cache whose Put can be cancelled with a context.
+type ContextCache interface {
+	Cache
+
+	// Same as Put, when ctx is done the extraction is stopped,
+	// nothing is left of the item and ctx.Err() is returned.
+	PutWithContext(ctx context.Context, key string, extractor Extractor) (string, error)
+}
+
 // Extractor extracts all its contents of an archive into the target location
 type Extractor interface {
 	// Extract contents to extractLocation using fs to write to the local file system.
 	Extract(extractLocation string, fs fs.FileSystem) error
 }
 
+// ContextExtractor is an Extractor whose extraction can be cancelled with a context.
+type ContextExtractor interface {
+	Extractor
+
+	// Same as Extract, returns ctx.Err() once ctx is done. The extracted files are left as is.
+	ExtractWithContext(ctx context.Context, extractLocation string, fs fs.FileSystem) error
+}
+
+// SourcedExtractor is an Extractor that knows the URL of the bundle
+// it extracts from. Caches can use it to record where an item came from.
+type SourcedExtractor interface {
+	Extractor
+
+	// URL of the bundle the contents are extracted from
+	Source() string
+}
+
+// ExtractOptions selects the file metadata kept from the archives of a bundle when they are
+// extracted: modification times, ownership when running as root, and extended attributes.
+// It also limits the bytes, entries and path depth of each archive, which fail with an
+// *archiver.ViolationError past them. The zero value only keeps file modes and has no limits.
+// Archives extracted with different preserve options are different items of the Cache.
+type ExtractOptions = archiver.ExtractOptions
+
 // ProgressCallback returns information about the download and extraction
 // of the bundle to the caller.
 type ProgressCallback func(percentDone float32, timeElapsed time.Duration)
@@ -72,7 +112,12 @@ type ProgressCallback func(percentDone float32, timeElapsed time.Duration)
 type Provider struct {
 	bundleStore                   Cache
 	progressCallback              ProgressCallback
+	progressObserver              ProgressObserver
 	progressCallbackRateInSeconds int
+	extractOptions                ExtractOptions
+	extractConcurrency            int
+	logger                        *slog.Logger
+	metrics                       metrics.Recorder
 }
 
 // NewProvider creates a provider which uses the passed in Cache
@@ -81,63 +126,183 @@ func NewProvider(bundleStore Cache) *Provider {
 	return &Provider{
 		bundleStore:                   bundleStore,
 		progressCallbackRateInSeconds: 1,
+		logger:                        logging.Discard(),
+		metrics:                       metrics.Discard(),
 	}
 }
 
 // SetProgressCallback accepts a function to be invoked
 // at regular intervals during download and extraction.
+// Overlays already in the Cache are not read, and don't count in the progress.
+// See SetProgressObserver for the bytes read, the phase and the overlay.
 func (b *Provider) SetProgressCallback(callback ProgressCallback) {
 	b.progressCallback = callback
 }
 
+// SetProgressObserver sets an observer notified at regular intervals during download and
+// extraction, and whenever the phase or the overlay being processed changes. The last
+// event of a bundle that is extracted has Done set.
+func (b *Provider) SetProgressObserver(observer ProgressObserver) {
+	b.progressObserver = observer
+}
+
 // SetProgressCallbackRate sets the rate in seconds the progress
-// callback should be invoked.
+// callback and observer should be invoked.
 func (b *Provider) SetProgressCallbackRate(rateSeconds int) {
 	b.progressCallbackRateInSeconds = rateSeconds
 }
 
+// SetExtractOptions sets the file metadata kept and the limits applied when extracting
+// bundles, by default only file modes are kept and there are no limits.
+func (b *Provider) SetExtractOptions(options ExtractOptions) {
+	b.extractOptions = options
+}
+
+// SetExtractConcurrency sets how many overlays of a v2 bundle are extracted at the same time.
+// Each of them reads its own part of the bundle: local bundles are read at several offsets, remote
+// ones are streamed again for every overlay. By default, or when workers is 0, local bundles are
+// extracted by as many workers as there are CPUs and remote ones by a single worker, so that a
+// bundle opens one connection to its source.
+func (b *Provider) SetExtractConcurrency(workers int) {
+	b.extractConcurrency = workers
+}
+
+// extractConcurrencyFor returns how many overlays are extracted at the same time from bundleStream
+func (b *Provider) extractConcurrencyFor(bundleStream io.ReadSeeker) int {
+	if b.extractConcurrency > 0 {
+		return b.extractConcurrency
+	}
+	if _, isReaderAt := bundleStream.(io.ReaderAt); isReaderAt {
+		return runtime.NumCPU()
+	}
+	return 1
+}
+
+// SetLogger sets the logger recording the processing of bundles, the provider is silent by default.
+func (b *Provider) SetLogger(logger *slog.Logger) {
+	b.logger = logging.OrDiscard(logger)
+}
+
+// SetMetrics sets the recorder counting the bundles fetched and timing them, by error type.
+// No metrics are recorded by default.
+func (b *Provider) SetMetrics(recorder metrics.Recorder) {
+	b.metrics = metrics.OrDiscard(recorder)
+}
+
 // GetBundle fetches and extracts the bundle pointed to by url
 // and returns its representation.
 func (b *Provider) GetBundle(url string) (Bundle, error) {
 	return b.GetVersionedBundle(url, "")
 }
 
+// GetBundleWithContext is the same as GetBundle, see GetVersionedBundleWithContext for cancellation.
+func (b *Provider) GetBundleWithContext(ctx context.Context, url string) (Bundle, error) {
+	return b.GetVersionedBundleWithContext(ctx, url, "")
+}
+
 // GetVersionedBundle fetches and extracts the bundle pointed to by
 // URL and verifies its hash matches the passed in expectedContentID.
 // For S3 downloads the etag is used.
 func (b *Provider) GetVersionedBundle(url string, expectedContentID string) (Bundle, error) {
+	return b.GetVersionedBundleWithContext(context.Background(), url, expectedContentID)
+}
+
+// GetVersionedBundleWithContext is the same as GetVersionedBundle, but stops the download
+// and extraction when ctx is done. The error is then of type ErrorTypeCanceled, and the
+// items of the bundle being extracted are removed when the Cache is a ContextCache.
+func (b *Provider) GetVersionedBundleWithContext(ctx context.Context, url string, expectedContentID string) (Bundle, error) {
+	startTime := time.Now()
+	bundle, err := b.getVersionedBundle(ctx, url, expectedContentID)
+
+	labels := metrics.Labels{metrics.LabelErrorType: metrics.ErrorTypeNone}
+	if bundleErr, ok := err.(*Error); ok {
+		labels[metrics.LabelErrorType] = bundleErr.GetErrorType()
+	}
+	b.metrics.AddCounter(metrics.BundleRequestsTotal, 1, labels)
+	b.metrics.ObserveHistogram(metrics.BundleRequestDurationSeconds, time.Since(startTime).Seconds(), labels)
+
+	return bundle, err
+}
+
+func (b *Provider) getVersionedBundle(ctx context.Context, url string, expectedContentID string) (Bundle, error) {
 	// convert our URL to a readable seekable stream
-	stream, contentLength, contentID, streamErr := stream.URLToStream(url)
+	stream, contentLength, contentID, streamErr := stream.URLToStreamWithContext(ctx, url)
 	if streamErr != nil {
-		return nil, newBundleError(streamErr, ErrorTypeSource)
+		return nil, newBundleErrorWithContext(ctx, streamErr, ErrorTypeSource)
 	}
+	// the items of the bundle are extracted once Extract returns, the stream isn't needed anymore
+	defer closeStream(stream)
 
 	if expectedContentID != "" && expectedContentID != contentID {
 		return nil, newBundleError(fmt.Errorf("Expected content ID [%v] does not match actual content ID [%v]", expectedContentID, contentID), ErrorTypeContentID)
 	}
 
-	if b.progressCallback != nil {
-		stream = &proxyReadSeeker{
-			r:                     stream,
-			contentLength:         contentLength,
-			callback:              b.progressCallback,
-			callbackRateInSeconds: b.progressCallbackRateInSeconds,
-			readStartTime:         time.Now(),
-			lastUpdated:           time.Now(),
-		}
+	var progress *progressTracker
+	if observer := b.observer(); observer != nil {
+		progress = newProgressTracker(observer, time.Duration(b.progressCallbackRateInSeconds)*time.Second, contentLength)
+		stream = newProgressReadSeeker(stream, progress)
 	}
 
+	config := processorConfig{logger: b.logger, workers: b.extractConcurrencyFor(stream)}
 	// create a bundle archive for the stream
-	bundleArchive, bundleArchiveErr := newBundleArchive(stream)
+	bundleArchive, bundleArchiveErr := newBundleArchive(stream, url, contentID, config)
 	if bundleArchiveErr != nil {
-		return nil, newBundleError(bundleArchiveErr, ErrorTypeFormat)
+		return nil, newBundleErrorWithContext(ctx, bundleArchiveErr, ErrorTypeFormat)
 	}
 
 	// ask our bundle archive to Extract
-	bundle, extractErr := bundleArchive.Extract(b.bundleStore)
+	bundle, extractErr := bundleArchive.Extract(ctx, b.bundleStore, b.extractOptions)
 	if extractErr != nil {
-		return nil, newBundleError(extractErr, ErrorTypeExtraction)
+		return nil, newBundleErrorWithContext(ctx, extractErr, ErrorTypeExtraction)
 	}
 
+	progress.finish()
 	return bundle, nil
 }
+
+// observer notifies the progress observer and callback, it is nil if there are none
+func (b *Provider) observer() ProgressObserver {
+	observer, callback := b.progressObserver, b.progressCallback
+	switch {
+	case callback == nil:
+		return observer
+	case observer == nil:
+		return ProgressObserverFunc(func(event ProgressEvent) {
+			callback(event.PercentDone(), event.Elapsed)
+		})
+	default:
+		return ProgressObserverFunc(func(event ProgressEvent) {
+			observer.OnProgress(event)
+			callback(event.PercentDone(), event.Elapsed)
+		})
+	}
+}
+
+// putWithContext uses PutWithContext on ContextCaches, other caches are only cancelled before the Put
+func putWithContext(ctx context.Context, bundleStore Cache, key string, extractor Extractor) (string, error) {
+	if contextCache, ok := bundleStore.(ContextCache); ok {
+		return contextCache.PutWithContext(ctx, key, extractor)
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return "", ctxErr
+	}
+	return bundleStore.Put(key, extractor)
+}
+
+// extractWithContext uses ExtractWithContext on ContextExtractors, other extractors are only cancelled before extracting
+func extractWithContext(ctx context.Context, extractor Extractor, extractLocation string, fs fs.FileSystem) error {
+	if contextExtractor, ok := extractor.(ContextExtractor); ok {
+		return contextExtractor.ExtractWithContext(ctx, extractLocation, fs)
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return extractor.Extract(extractLocation, fs)
+}
+
+// closeStream closes streams which are io.Closers, remote streams keep their connection open until closed
+func closeStream(inputStream io.Reader) {
+	if closer, ok := inputStream.(io.Closer); ok {
+		closer.Close()
+	}
+}
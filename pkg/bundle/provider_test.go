@@ -0,0 +1,102 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"bytes"
+	"context"
+	bundleprometheus "github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/metrics/prometheus"
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestProvider_GetVersionedBundleWithContext_WhenCancelled_ShouldReturnCanceledError(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	provider := NewProvider(NewMockCache(ctrl))
+	bundle, err := provider.GetVersionedBundleWithContext(ctx, "/path/to/bundle.tar", "")
+
+	assert.Nil(t, bundle)
+	bundleErr, ok := err.(*Error)
+	assert.True(t, ok)
+	assert.Equal(t, ErrorTypeCanceled, bundleErr.GetErrorType())
+	assert.Equal(t, context.Canceled, bundleErr.GetCause())
+}
+
+func TestProvider_GetVersionedBundleWithContext_ShouldRecordMetricsByErrorType(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	recorder := bundleprometheus.NewRecorder()
+	provider := NewProvider(NewMockCache(ctrl))
+	provider.SetMetrics(recorder)
+	provider.GetVersionedBundleWithContext(ctx, "/path/to/bundle.tar", "")
+	provider.GetVersionedBundleWithContext(ctx, "/path/to/bundle.tar", "")
+
+	metricsText := exposition(t, recorder)
+	assert.Contains(t, metricsText, "\nbundle_requests_total{error_type=\"CANCELED\"} 2\n")
+	assert.Contains(t, metricsText, "\nbundle_request_duration_seconds_count{error_type=\"CANCELED\"} 2\n")
+}
+
+func TestProvider_GetVersionedBundle_WithMismatchingContentID_ShouldCloseStream(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bundlePath, _ := createTestBundle(t)
+	defer os.RemoveAll(filepath.Dir(bundlePath))
+	url := closingStreamerScheme + bundlePath
+
+	bundle, err := NewProvider(NewMockCache(ctrl)).GetVersionedBundle(url, "other")
+
+	assert.Nil(t, bundle)
+	bundleErr, ok := err.(*Error)
+	assert.True(t, ok)
+	assert.Equal(t, ErrorTypeContentID, bundleErr.GetErrorType())
+	assert.Equal(t, 0, testClosingStreamer.openStreamsOf(url))
+}
+
+func TestProvider_ExtractConcurrencyFor_ByDefault_ShouldUseOneWorkerForRemoteBundles(t *testing.T) {
+	t.Parallel()
+	provider := NewProvider(nil)
+
+	// local bundles can be read at several offsets, remote ones would be streamed again by every worker
+	assert.Equal(t, runtime.NumCPU(), provider.extractConcurrencyFor(bytes.NewReader(nil)))
+	assert.Equal(t, 1, provider.extractConcurrencyFor(struct{ io.ReadSeeker }{bytes.NewReader(nil)}))
+
+	provider.SetExtractConcurrency(4)
+	assert.Equal(t, 4, provider.extractConcurrencyFor(struct{ io.ReadSeeker }{bytes.NewReader(nil)}))
+}
+
+// exposition gathers the metrics of recorder in the Prometheus text exposition format
+func exposition(t *testing.T, recorder *bundleprometheus.Recorder) string {
+	registry := prometheus.NewRegistry()
+	assert.Nil(t, registry.Register(recorder))
+	families, gatherErr := registry.Gather()
+	assert.Nil(t, gatherErr)
+
+	var out strings.Builder
+	encoder := expfmt.NewEncoder(&out, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, family := range families {
+		assert.Nil(t, encoder.Encode(family))
+	}
+	return out.String()
+}
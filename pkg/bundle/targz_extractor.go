@@ -8,8 +8,10 @@ package bundle
 //go:generate mockgen -destination=mock_file_info.go -package=bundle github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs FileInfo
 
 import (
+	"context"
 	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/3p/archiver"
 	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/stream"
 	"io"
 )
 
@@ -18,20 +20,24 @@ type tarGzExtractor struct {
 	// the stream where the tar.gz bytes are read from
 	readStream        io.Reader
 	archiverInterface archiver.Archiver
+
+	// the file metadata kept while extracting
+	options ExtractOptions
 }
 
-func newTarExtractor(reader io.Reader) *tarGzExtractor {
-	return newExtractor(reader, archiver.Tar)
+func newTarExtractor(reader io.Reader, options ExtractOptions) *tarGzExtractor {
+	return newExtractor(reader, archiver.Tar, options)
 }
 
-func newExtractor(reader io.Reader, archiverInterface archiver.Archiver) *tarGzExtractor {
+func newExtractor(reader io.Reader, archiverInterface archiver.Archiver, options ExtractOptions) *tarGzExtractor {
 	return &tarGzExtractor{
 		readStream:        reader,
 		archiverInterface: archiverInterface,
+		options:           options,
 	}
 }
 
-func extractorFromFileName(reader io.Reader, fileName string) *tarGzExtractor {
+func extractorFromFileName(reader io.Reader, fileName string, options ExtractOptions) *tarGzExtractor {
 	archiverInterface := archiver.MatchingFormat(fileName)
 
 	if archiverInterface == nil {
@@ -41,6 +47,7 @@ func extractorFromFileName(reader io.Reader, fileName string) *tarGzExtractor {
 	return &tarGzExtractor{
 		readStream:        reader,
 		archiverInterface: archiverInterface,
+		options:           options,
 	}
 }
 
@@ -48,6 +55,14 @@ func (e *tarGzExtractor) Extract(extractLocation string, fs fs.FileSystem) error
 	return e.ExtractWithArchiver(extractLocation, fs, e.archiverInterface)
 }
 
+func (e *tarGzExtractor) ExtractWithContext(ctx context.Context, extractLocation string, fs fs.FileSystem) error {
+	// keep the stream seekable for archive formats which need random access
+	if seeker, isSeeker := e.readStream.(io.ReadSeeker); isSeeker {
+		return newExtractor(stream.NewContextReadSeeker(ctx, seeker), e.archiverInterface, e.options).Extract(extractLocation, fs)
+	}
+	return newExtractor(stream.NewContextReader(ctx, e.readStream), e.archiverInterface, e.options).Extract(extractLocation, fs)
+}
+
 func (e *tarGzExtractor) ExtractWithArchiver(extractLocation string, fs fs.FileSystem, archiverInterface archiver.Archiver) error {
 	// crete the Extract location if it doesn't exist
 	extractLocationErr := fs.MkdirAll(extractLocation, defaultFileMode)
@@ -56,7 +71,7 @@ func (e *tarGzExtractor) ExtractWithArchiver(extractLocation string, fs fs.FileS
 	}
 
 	// Now, Extract the bytes
-	extractErr := archiverInterface.Read(e.readStream, extractLocation)
+	extractErr := archiverInterface.ReadWithOptions(e.readStream, extractLocation, fs, e.options)
 	if extractErr != nil {
 		return extractErr
 	}
@@ -25,7 +25,7 @@ func TestTarGzExtractor_Extract_WithNoErrors_ShouldExtract(t *testing.T) {
 	mockFileSystem := NewMockFileSystem(ctrl)
 
 	mockFileSystem.EXPECT().MkdirAll(extractLocation, expectedFileMode).Return(nil)
-	mockArchiver.EXPECT().Read(nil, extractLocation).Return(nil)
+	mockArchiver.EXPECT().ReadWithOptions(nil, extractLocation, mockFileSystem, ExtractOptions{}).Return(nil)
 
 	extractor := tarGzExtractor{}
 	extractErr := extractor.ExtractWithArchiver(extractLocation, mockFileSystem, mockArchiver)
@@ -63,7 +63,7 @@ func TestTarGzExtractor_Extract_WithExtractErrors_ShouldError(t *testing.T) {
 	tarGzErr := errors.New("tarGzErr")
 
 	mockFileSystem.EXPECT().MkdirAll(extractLocation, expectedFileMode).Return(nil)
-	mockArchiver.EXPECT().Read(nil, extractLocation).Return(tarGzErr)
+	mockArchiver.EXPECT().ReadWithOptions(nil, extractLocation, mockFileSystem, ExtractOptions{}).Return(tarGzErr)
 
 	extractor := tarGzExtractor{}
 	extractErr := extractor.ExtractWithArchiver(extractLocation, mockFileSystem, mockArchiver)
@@ -4,7 +4,9 @@
 package bundle
 
 import (
-	"github.com/google/uuid"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 )
 
@@ -15,16 +17,45 @@ func newBundleProcessorV1() bundleProcessor {
 // bundle v1 simply extracts tar.gz
 type bundleProcessorV1 struct{}
 
-func (b *bundleProcessorV1) extract(inputStream io.ReadSeeker, bundleStore Cache) (Bundle, error) {
+func (b *bundleProcessorV1) extract(ctx context.Context, inputStream io.ReadSeeker, bundleStore Cache, source string, contentID string, options ExtractOptions) (Bundle, error) {
 	// create a bundle extractor that knows how to Extract the bundle
-	bundleExtractor := newBundleV1Extractor(inputStream)
+	bundleExtractor := newBundleV1Extractor(inputStream, source, options)
+
+	// the bundle is keyed by its content, so that the store reuses it instead of extracting it again
+	contentKey, keyErr := bundleKeyV1(inputStream, source, contentID)
+	if keyErr != nil {
+		return nil, keyErr
+	}
+	bundleKey := itemKey(contentKey, options)
 
-	bundleKey := uuid.New().String()
 	// put it into the store
-	// for bundle v1, we plan to ask the higher-up caller for the key, use 12345 for now
-	_, putErr := bundleStore.Put(bundleKey, bundleExtractor)
+	progressOf(inputStream).setPhase(ProgressPhaseExtract, "")
+	_, putErr := putWithContext(ctx, bundleStore, bundleKey, bundleExtractor)
 	if putErr != nil {
 		return nil, putErr
 	}
 	return newBundle(bundleStore, []string{bundleKey}), nil
 }
+
+// bundleKeyV1 derives the key of a v1 bundle from the content ID of its stream. Content IDs are only
+// unique for a given URL (an HTTP Last-Modified date), so the key is the sha256 of both. Without
+// a content ID the key is the sha256 of the bundle, which is read once more to compute it.
+func bundleKeyV1(inputStream io.ReadSeeker, source string, contentID string) (string, error) {
+	hash := sha256.New()
+	if contentID != "" {
+		io.WriteString(hash, source+"\n"+contentID)
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	// the bundle is read a second time to be extracted
+	progress := progressOf(inputStream)
+	progress.expectAgain()
+	progress.setPhase(ProgressPhaseVerify, "")
+	if _, copyErr := io.Copy(hash, inputStream); copyErr != nil {
+		return "", copyErr
+	}
+	if _, seekErr := inputStream.Seek(0, io.SeekStart); seekErr != nil {
+		return "", seekErr
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -4,15 +4,18 @@
 package bundle
 
 import (
+	"context"
 	"fmt"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"strings"
 
 	"testing"
 )
 
 const (
-	rootPath = "/testing_root"
+	rootPath      = "/testing_root"
+	testContentID = "contentID"
 )
 
 // Matcher that tests for v1Extractor
@@ -43,7 +46,7 @@ func TestBundleProcessorV1_Extract_ShouldPutIntoStore(t *testing.T) {
 	mockBundleStore.EXPECT().Put(gomock.Any(), OfExtractorV1()).Return(path, nil)
 
 	extractor := newBundleProcessorV1()
-	bundle, err := extractor.extract(nil, mockBundleStore)
+	bundle, err := extractor.extract(context.Background(), nil, mockBundleStore, "", testContentID, ExtractOptions{})
 
 	assert.NotNil(t, bundle)
 	assert.Nil(t, err)
@@ -62,9 +65,68 @@ func TestBundleProcessorV1_Extract_WithError_ShouldReturnError(t *testing.T) {
 	mockBundleStore.EXPECT().Put(gomock.Any(), OfExtractorV1()).Return(path, expectedError)
 
 	extractor := newBundleProcessorV1()
-	bundle, err := extractor.extract(nil, mockBundleStore)
+	bundle, err := extractor.extract(context.Background(), nil, mockBundleStore, "", testContentID, ExtractOptions{})
 
 	assert.Nil(t, bundle)
 	assert.NotNil(t, err)
 	assert.Equal(t, expectedError, err)
 }
+
+func TestBundleProcessorV1_Extract_WithSameContentID_ShouldUseSameKey(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var keys []string
+	mockBundleStore := NewMockCache(ctrl)
+	mockBundleStore.EXPECT().Put(gomock.Any(), OfExtractorV1()).DoAndReturn(func(key string, extractor Extractor) (string, error) {
+		keys = append(keys, key)
+		return key, nil
+	}).Times(3)
+
+	processor := newBundleProcessorV1()
+	processor.extract(context.Background(), nil, mockBundleStore, "s3://bucket/bundle.tar", testContentID, ExtractOptions{})
+	processor.extract(context.Background(), nil, mockBundleStore, "s3://bucket/bundle.tar", testContentID, ExtractOptions{})
+	processor.extract(context.Background(), nil, mockBundleStore, "s3://bucket/other.tar", testContentID, ExtractOptions{})
+
+	assert.Equal(t, keys[0], keys[1])
+	assert.NotEqual(t, keys[0], keys[2])
+}
+
+func TestBundleProcessorV1_Extract_WithPreserveOptions_ShouldUseOtherKey(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var keys []string
+	mockBundleStore := NewMockCache(ctrl)
+	mockBundleStore.EXPECT().Put(gomock.Any(), OfExtractorV1()).DoAndReturn(func(key string, extractor Extractor) (string, error) {
+		keys = append(keys, key)
+		return key, nil
+	}).Times(2)
+
+	processor := newBundleProcessorV1()
+	processor.extract(context.Background(), nil, mockBundleStore, "s3://bucket/bundle.tar", testContentID, ExtractOptions{})
+	processor.extract(context.Background(), nil, mockBundleStore, "s3://bucket/bundle.tar", testContentID, ExtractOptions{PreserveOwnership: true})
+
+	// a bundle extracted without the ownership of its files isn't reused when it is needed
+	assert.NotEqual(t, keys[0], keys[1])
+}
+
+func TestBundleProcessorV1_Extract_WithoutContentID_ShouldUseDigestOfBundle(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const bundleContent = "bundle content"
+	mockBundleStore := NewMockCache(ctrl)
+	mockBundleStore.EXPECT().Put(sha256Hex([]byte(bundleContent)), OfExtractorV1()).Return(rootPath, nil)
+
+	inputStream := strings.NewReader(bundleContent)
+	bundle, err := newBundleProcessorV1().extract(context.Background(), inputStream, mockBundleStore, "", "", ExtractOptions{})
+
+	assert.NotNil(t, bundle)
+	assert.Nil(t, err)
+	// the extractor reads the bundle from the start
+	assert.Equal(t, int64(len(bundleContent)), int64(inputStream.Len()))
+}
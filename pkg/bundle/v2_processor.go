@@ -6,10 +6,15 @@ package bundle
 import (
 	"archive/tar"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/logging"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/stream"
 	"io"
 	"io/ioutil"
+	"log/slog"
+	"sync"
 )
 
 const (
@@ -17,15 +22,23 @@ const (
 	overlaysFileName   = "overlays.json"
 )
 
-func newBundleProcessorV2() bundleProcessor {
-	return &bundleProcessorV2{}
+func newBundleProcessorV2(config processorConfig) bundleProcessor {
+	return &bundleProcessorV2{logger: logging.OrDiscard(config.logger), workers: config.workers}
 }
 
 // bundle v2 processor knows how to parse overlays and process them accordingly
 type bundleProcessorV2 struct {
+	logger *slog.Logger
+
+	// maximum number of overlays extracted at the same time
+	workers int
 }
 
-func (b *bundleProcessorV2) extract(inputStream io.ReadSeeker, bundleStore Cache) (Bundle, error) {
+// overlayOpener opens a reader of an overlay which can be used concurrently with the readers of the other overlays.
+// The returned function releases the reader.
+type overlayOpener func(overlay overlay) (io.ReadSeeker, func(), error)
+
+func (b *bundleProcessorV2) extract(ctx context.Context, inputStream io.ReadSeeker, bundleStore Cache, source string, contentID string, options ExtractOptions) (Bundle, error) {
 
 	// obtain the metadata from the bundle bytes
 	metadataTarReader, metadataErr := getMetadataTarReader(inputStream)
@@ -39,36 +52,222 @@ func (b *bundleProcessorV2) extract(inputStream io.ReadSeeker, bundleStore Cache
 		return nil, overalysErr
 	}
 
+	// overlays are extracted concurrently when each of them can get its own reader of the bundle
 	var itemKeys []string
+	var extractErr error
+	openOverlay := newOverlayOpener(ctx, inputStream, source, contentID)
+	if b.workers > 1 && len(overlays.Overlays) > 1 && openOverlay != nil {
+		itemKeys, extractErr = b.extractConcurrently(ctx, overlays.Overlays, openOverlay, progressOf(inputStream), bundleStore, source, options)
+	} else {
+		itemKeys, extractErr = b.extractSequentially(ctx, overlays.Overlays, inputStream, bundleStore, source, options)
+	}
+	if extractErr != nil {
+		return nil, extractErr
+	}
 
-	// for every overlay, Extract them into the bundle store
-	for _, overlay := range overlays.Overlays {
+	// create a new bundle with item paths
+	return newBundle(bundleStore, itemKeys), nil
+}
 
-		fmt.Printf("Processing overlay: %+v\n", overlay)
+// extractSequentially extracts the overlays one after another from inputStream, it returns their item keys
+func (b *bundleProcessorV2) extractSequentially(ctx context.Context, overlays []overlay, inputStream io.ReadSeeker, bundleStore Cache, source string, options ExtractOptions) ([]string, error) {
+	var itemKeys []string
+	progress := progressOf(inputStream)
 
-		overlayReader, overlayErr := getReaderForOverlay(overlay, inputStream)
-		if overlayErr != nil {
-			return nil, overlayErr
+	// for every overlay, Extract them into the bundle store
+	for _, overlay := range overlays {
+		var overlayReader io.ReadSeeker
+		if bundleStore.Exists(itemKey(overlay.Sha256, options)) {
+			// the section is only read if the item is removed before the store reuses it
+			progress.skip(int64(overlay.Size))
+			overlayReader = stream.NewSectionReader(inputStream, int64(overlay.Offset), int64(overlay.Size))
+		} else {
+			var overlayErr error
+			overlayReader, overlayErr = getReaderForOverlay(overlay, inputStream)
+			if overlayErr != nil {
+				releaseItems(bundleStore, itemKeys)
+				return nil, overlayErr
+			}
 		}
 
-		tarGzExtractor := extractorFromFileName(overlayReader, overlay.FileName)
-		if tarGzExtractor == nil {
-			return nil, fmt.Errorf("cannot create extractor for overlay: %s", overlay.FileName)
+		if putError := b.putOverlay(ctx, overlay, overlayReader, progress, bundleStore, source, options); putError != nil {
+			releaseItems(bundleStore, itemKeys)
+			return nil, putError
 		}
+		itemKeys = append(itemKeys, itemKey(overlay.Sha256, options))
+	}
+	return itemKeys, nil
+}
 
-		// now, put into the bundle store, the store will take care of not extracting if it already exists
-		_, putError := bundleStore.Put(overlay.Sha256, tarGzExtractor)
-		if putError != nil {
-			return nil, putError
+// extractConcurrently extracts the overlays with up to b.workers of them at the same time, each one from its own
+// reader given by openOverlay. It returns their item keys in the order of the overlays.
+// When an overlay fails, the extraction of the others is cancelled, and the ones already put are released.
+func (b *bundleProcessorV2) extractConcurrently(ctx context.Context, overlays []overlay, openOverlay overlayOpener, progress *progressTracker, bundleStore Cache, source string, options ExtractOptions) ([]string, error) {
+	workerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	put := make([]bool, len(overlays))
+	var firstErr error
+	var errOnce sync.Once
+
+	// every overlay is handed out, the workers skip the ones left once the extraction is cancelled
+	indexes := make(chan int, len(overlays))
+	for i := range overlays {
+		indexes <- i
+	}
+	close(indexes)
+
+	var workers sync.WaitGroup
+	for worker := 0; worker < b.workers && worker < len(overlays); worker++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range indexes {
+				if workerCtx.Err() != nil {
+					continue
+				}
+				if putErr := b.openAndPutOverlay(workerCtx, overlays[i], openOverlay, progress, bundleStore, source, options); putErr != nil {
+					// the errors of the overlays cancelled because of this one don't matter
+					errOnce.Do(func() {
+						firstErr = putErr
+						cancel()
+					})
+					continue
+				}
+				put[i] = true
+			}
+		}()
+	}
+	workers.Wait()
+
+	var itemKeys []string
+	for i, overlay := range overlays {
+		if put[i] {
+			itemKeys = append(itemKeys, itemKey(overlay.Sha256, options))
 		}
-		itemKeys = append(itemKeys, overlay.Sha256)
 	}
+	if firstErr == nil && len(itemKeys) < len(overlays) {
+		// the overlays left were skipped because ctx was done, a bundle fully extracted before is kept
+		firstErr = ctx.Err()
+	}
+	if firstErr != nil {
+		releaseItems(bundleStore, itemKeys)
+		return nil, firstErr
+	}
+	return itemKeys, nil
+}
 
-	//Seek to the end of the stream to expose completion to clients monitoring progress (we might not read everything)
-	_, _ = inputStream.Seek(0, io.SeekEnd)
+func (b *bundleProcessorV2) openAndPutOverlay(ctx context.Context, overlay overlay, openOverlay overlayOpener, progress *progressTracker, bundleStore Cache, source string, options ExtractOptions) error {
+	if bundleStore.Exists(itemKey(overlay.Sha256, options)) {
+		progress.skip(int64(overlay.Size))
+		// the overlay is only opened if the item is removed before the store reuses it
+		overlayReader := &lazyReadSeeker{open: func() (io.ReadSeeker, func(), error) { return openOverlay(overlay) }}
+		defer overlayReader.release()
+		return b.putOverlay(ctx, overlay, overlayReader, progress, bundleStore, source, options)
+	}
 
-	// create a new bundle with item paths
-	return newBundle(bundleStore, itemKeys), nil
+	overlayReader, release, openErr := openOverlay(overlay)
+	if openErr != nil {
+		return openErr
+	}
+	defer release()
+	return b.putOverlay(ctx, overlay, overlayReader, progress, bundleStore, source, options)
+}
+
+// putOverlay extracts the overlay read from overlayReader into the bundle store, reporting its phases to progress
+func (b *bundleProcessorV2) putOverlay(ctx context.Context, overlay overlay, overlayReader io.ReadSeeker, progress *progressTracker, bundleStore Cache, source string, options ExtractOptions) error {
+	b.logger.Info("processing overlay",
+		logging.KeyOverlay, overlay.FileName,
+		logging.KeySha256, overlay.Sha256,
+		"offset", overlay.Offset,
+		logging.KeyBytes, overlay.Size)
+
+	// the overlay is verified against its sha256 while extracting, as the sha256 is the key it is trusted under
+	overlayExtractor := newIntegrityExtractor(overlayReader, overlay.FileName, overlay.Sha256, source, options)
+	if overlayExtractor == nil {
+		return fmt.Errorf("cannot create extractor for overlay: %s", overlay.FileName)
+	}
+	overlayExtractor.progress = progress
+
+	// now, put into the bundle store, the store will take care of not extracting if it already exists
+	_, putError := putWithContext(ctx, bundleStore, itemKey(overlay.Sha256, options), overlayExtractor)
+	return putError
+}
+
+// lazyReadSeeker opens the reader it reads from when it is first used
+type lazyReadSeeker struct {
+	open    func() (io.ReadSeeker, func(), error)
+	r       io.ReadSeeker
+	close   func()
+	openErr error
+}
+
+func (l *lazyReadSeeker) reader() (io.ReadSeeker, error) {
+	if l.r == nil && l.openErr == nil {
+		l.r, l.close, l.openErr = l.open()
+	}
+	return l.r, l.openErr
+}
+
+func (l *lazyReadSeeker) Read(p []byte) (int, error) {
+	r, openErr := l.reader()
+	if openErr != nil {
+		return 0, openErr
+	}
+	return r.Read(p)
+}
+
+func (l *lazyReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	r, openErr := l.reader()
+	if openErr != nil {
+		return 0, openErr
+	}
+	return r.Seek(offset, whence)
+}
+
+// release releases the reader if it was opened
+func (l *lazyReadSeeker) release() {
+	if l.close != nil {
+		l.close()
+	}
+}
+
+// newOverlayOpener returns how overlays are read concurrently: with section readers of inputStream when it
+// can read at an offset, like local files, and otherwise with a new stream of source for each overlay,
+// which must have the same contentID. Returns nil when overlays can only be read from inputStream.
+// The bytes read by the opened readers count in the progress of inputStream.
+func newOverlayOpener(ctx context.Context, inputStream io.ReadSeeker, source string, contentID string) overlayOpener {
+	if readerAt, ok := inputStream.(io.ReaderAt); ok {
+		return func(overlay overlay) (io.ReadSeeker, func(), error) {
+			return io.NewSectionReader(readerAt, int64(overlay.Offset), int64(overlay.Size)), func() {}, nil
+		}
+	}
+	if source == "" {
+		return nil
+	}
+	progress := progressOf(inputStream)
+
+	return func(overlay overlay) (io.ReadSeeker, func(), error) {
+		overlayStream, _, overlayContentID, streamErr := stream.URLToStreamWithContext(ctx, source)
+		if streamErr != nil {
+			return nil, nil, streamErr
+		}
+		release := func() {
+			closeStream(overlayStream)
+		}
+		if overlayContentID != contentID {
+			release()
+			return nil, nil, fmt.Errorf("content ID of %s changed from [%v] to [%v] while extracting %s", source, contentID, overlayContentID, overlay.FileName)
+		}
+		return newProgressReadSeeker(stream.NewSectionReader(overlayStream, int64(overlay.Offset), int64(overlay.Size)), progress), release, nil
+	}
+}
+
+// releaseItems releases the items of a bundle which failed to extract, so that they can be cleaned up
+func releaseItems(bundleStore Cache, itemKeys []string) {
+	for _, itemKey := range itemKeys {
+		bundleStore.Release(itemKey)
+	}
 }
 
 // from the input stream get the metadata tar reader
@@ -130,14 +329,18 @@ func getOverlays(metadataTarReader *tar.Reader) (*overlays, error) {
 	return nil, fmt.Errorf("overlays file not find in metadata")
 }
 
-func getReaderForOverlay(overlay overlay, inputStream io.ReadSeeker) (io.Reader, error) {
-	// now we seek and create a limit reader, and get extractor
+func getReaderForOverlay(overlay overlay, inputStream io.ReadSeeker) (io.ReadSeeker, error) {
+	// now we seek and create a section reader, and get extractor
 	_, seekError := inputStream.Seek(int64(overlay.Offset), io.SeekStart)
 
 	if seekError != nil {
 		return nil, fmt.Errorf("seekError: %v for %s", seekError, overlay.FileName)
 	}
 
-	// create a limit reader to read part of a file
-	return io.LimitReader(inputStream, int64(overlay.Size)), nil
+	// create a section reader to read part of a file, archive formats like zip need to seek in it
+	if readerAt, ok := inputStream.(io.ReaderAt); ok {
+		// zip overlays of local bundles are then read in place
+		return io.NewSectionReader(readerAt, int64(overlay.Offset), int64(overlay.Size)), nil
+	}
+	return stream.NewSectionReader(inputStream, int64(overlay.Offset), int64(overlay.Size)), nil
 }
@@ -0,0 +1,329 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/stream"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// writes a v2 bundle of overlayCount directory overlays into tempDir, the setup.sh of overlay i contains "overlay i"
+func writeMultiOverlayBundle(t *testing.T, tempDir string, overlayCount int) []byte {
+	var overlayPaths []string
+	for i := 0; i < overlayCount; i++ {
+		overlayPath := filepath.Join(tempDir, fmt.Sprintf("overlay-%d", i))
+		os.MkdirAll(overlayPath, 0755)
+		ioutil.WriteFile(filepath.Join(overlayPath, "setup.sh"), []byte(fmt.Sprintf("overlay %d", i)), 0644)
+		overlayPaths = append(overlayPaths, overlayPath)
+	}
+
+	var bundleBytes bytes.Buffer
+	assert.Nil(t, NewWriter().Write(&bundleBytes, overlayPaths))
+	return bundleBytes.Bytes()
+}
+
+// the contents of the setup.sh of the items of b, extracted into extractRoot/<key>
+func setupContents(b Bundle, extractRoot string) []string {
+	var contents []string
+	for _, key := range b.(*bundle).itemKeys {
+		content, _ := ioutil.ReadFile(filepath.Join(extractRoot, key, "setup.sh"))
+		contents = append(contents, string(content))
+	}
+	return contents
+}
+
+func TestBundleProcessorV2_Extract_WithWorkers_ShouldExtractConcurrentlyInOrder(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tempDir, _ := ioutil.TempDir("", "v2-processor")
+	defer os.RemoveAll(tempDir)
+	bundleBytes := writeMultiOverlayBundle(t, tempDir, 4)
+	extractRoot := filepath.Join(tempDir, "extracted")
+
+	// every Put waits for all the overlays to be put at the same time
+	var inFlight sync.WaitGroup
+	inFlight.Add(4)
+	allInFlight := make(chan struct{})
+	go func() {
+		inFlight.Wait()
+		close(allInFlight)
+	}()
+	mockBundleStore := NewMockCache(ctrl)
+	mockBundleStore.EXPECT().Exists(gomock.Any()).Return(false).AnyTimes()
+	mockBundleStore.EXPECT().Put(gomock.Any(), gomock.Any()).DoAndReturn(func(key string, extractor Extractor) (string, error) {
+		inFlight.Done()
+		select {
+		case <-allInFlight:
+		case <-time.After(5 * time.Second):
+			return "", errors.New("overlays weren't put concurrently")
+		}
+		itemPath := filepath.Join(extractRoot, key)
+		return itemPath, extractor.Extract(itemPath, fs.NewLocalFS())
+	}).Times(4)
+
+	processor := newBundleProcessorV2(processorConfig{workers: 4})
+	b, extractErr := processor.extract(context.Background(), bytes.NewReader(bundleBytes), mockBundleStore, "", "", ExtractOptions{})
+
+	assert.Nil(t, extractErr)
+	assert.Equal(t, []string{"overlay 0", "overlay 1", "overlay 2", "overlay 3"}, setupContents(b, extractRoot))
+}
+
+func TestBundleProcessorV2_Extract_WithWorkersAndNoReaderAt_ShouldStreamSourceForEachOverlay(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tempDir, _ := ioutil.TempDir("", "v2-processor")
+	defer os.RemoveAll(tempDir)
+	bundlePath := filepath.Join(tempDir, "bundle.tar")
+	ioutil.WriteFile(bundlePath, writeMultiOverlayBundle(t, tempDir, 3), 0644)
+	extractRoot := filepath.Join(tempDir, "extracted")
+
+	mockBundleStore := NewMockCache(ctrl)
+	mockBundleStore.EXPECT().Exists(gomock.Any()).Return(false).AnyTimes()
+	mockBundleStore.EXPECT().Put(gomock.Any(), gomock.Any()).DoAndReturn(func(key string, extractor Extractor) (string, error) {
+		itemPath := filepath.Join(extractRoot, key)
+		return itemPath, extractor.Extract(itemPath, fs.NewLocalFS())
+	}).Times(3)
+
+	// a stream which can only be read sequentially, like remote ones
+	bundleStream, _, contentID, streamErr := stream.URLToStream(bundlePath)
+	assert.Nil(t, streamErr)
+	processor := newBundleProcessorV2(processorConfig{workers: 2})
+	b, extractErr := processor.extract(context.Background(), struct{ io.ReadSeeker }{bundleStream}, mockBundleStore, bundlePath, contentID, ExtractOptions{})
+
+	assert.Nil(t, extractErr)
+	assert.Equal(t, []string{"overlay 0", "overlay 1", "overlay 2"}, setupContents(b, extractRoot))
+}
+
+func TestBundleProcessorV2_Extract_WithWorkers_WhenOverlayFails_ShouldReleasePutOverlays(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tempDir, _ := ioutil.TempDir("", "v2-processor")
+	defer os.RemoveAll(tempDir)
+	bundleBytes := writeMultiOverlayBundle(t, tempDir, 4)
+
+	putErr := errors.New("put failed")
+	var puts, failures, releases int32
+	mockBundleStore := NewMockCache(ctrl)
+	mockBundleStore.EXPECT().Exists(gomock.Any()).Return(false).AnyTimes()
+	mockBundleStore.EXPECT().Put(gomock.Any(), gomock.Any()).DoAndReturn(func(key string, extractor Extractor) (string, error) {
+		if atomic.AddInt32(&puts, 1) == 2 {
+			atomic.AddInt32(&failures, 1)
+			return "", putErr
+		}
+		return key, nil
+	}).MinTimes(2)
+	mockBundleStore.EXPECT().Release(gomock.Any()).DoAndReturn(func(key string) error {
+		atomic.AddInt32(&releases, 1)
+		return nil
+	}).AnyTimes()
+
+	processor := newBundleProcessorV2(processorConfig{workers: 2})
+	b, extractErr := processor.extract(context.Background(), bytes.NewReader(bundleBytes), mockBundleStore, "", "", ExtractOptions{})
+
+	assert.Nil(t, b)
+	assert.Equal(t, putErr, extractErr)
+	assert.Equal(t, atomic.LoadInt32(&puts)-atomic.LoadInt32(&failures), atomic.LoadInt32(&releases))
+}
+
+func TestBundleProcessorV2_Extract_WithWorkers_WhenCancelledAfterLastOverlay_ShouldReturnBundle(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tempDir, _ := ioutil.TempDir("", "v2-processor")
+	defer os.RemoveAll(tempDir)
+	bundleBytes := writeMultiOverlayBundle(t, tempDir, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var puts int32
+	mockBundleStore := NewMockCache(ctrl)
+	mockBundleStore.EXPECT().Exists(gomock.Any()).Return(false).AnyTimes()
+	mockBundleStore.EXPECT().Put(gomock.Any(), gomock.Any()).DoAndReturn(func(key string, extractor Extractor) (string, error) {
+		// every overlay is already being put when ctx is done
+		if atomic.AddInt32(&puts, 1) == 2 {
+			cancel()
+		}
+		return key, nil
+	}).Times(2)
+
+	processor := newBundleProcessorV2(processorConfig{workers: 2})
+	b, extractErr := processor.extract(ctx, bytes.NewReader(bundleBytes), mockBundleStore, "", "", ExtractOptions{})
+
+	assert.Nil(t, extractErr)
+	assert.Equal(t, 2, len(b.(*bundle).itemKeys))
+}
+
+// the overlays of a v2 bundle, as listed in its metadata
+func overlaysOf(t *testing.T, bundleBytes []byte) []overlay {
+	metadataTarReader, metadataErr := getMetadataTarReader(bytes.NewReader(bundleBytes))
+	assert.Nil(t, metadataErr)
+	overlays, overlaysErr := getOverlays(metadataTarReader)
+	assert.Nil(t, overlaysErr)
+	return overlays.Overlays
+}
+
+// recordingReadSeeker records the offsets of the bytes read from it
+type recordingReadSeeker struct {
+	io.ReadSeeker
+	readOffsets []int64
+}
+
+func (r *recordingReadSeeker) Read(p []byte) (int, error) {
+	offset, _ := r.ReadSeeker.Seek(0, io.SeekCurrent)
+	n, err := r.ReadSeeker.Read(p)
+	for i := 0; i < n; i++ {
+		r.readOffsets = append(r.readOffsets, offset+int64(i))
+	}
+	return n, err
+}
+
+func TestBundleProcessorV2_Extract_WithCachedOverlay_ShouldNotReadIt(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tempDir, _ := ioutil.TempDir("", "v2-processor")
+	defer os.RemoveAll(tempDir)
+	// the first overlay pushes the cached one past what is read ahead while looking for the version of the bundle
+	padding := make([]byte, 16*1024)
+	rand.New(rand.NewSource(1)).Read(padding)
+	os.MkdirAll(filepath.Join(tempDir, "overlay-0"), 0755)
+	ioutil.WriteFile(filepath.Join(tempDir, "overlay-0", "padding"), padding, 0644)
+	bundleBytes := writeMultiOverlayBundle(t, tempDir, 3)
+	overlays := overlaysOf(t, bundleBytes)
+	cachedOverlay := overlays[1]
+
+	mockBundleStore := NewMockCache(ctrl)
+	mockBundleStore.EXPECT().Exists(gomock.Any()).DoAndReturn(func(key string) bool {
+		return key == cachedOverlay.Sha256
+	}).Times(3)
+	mockBundleStore.EXPECT().Put(gomock.Any(), gomock.Any()).DoAndReturn(func(key string, extractor Extractor) (string, error) {
+		// the store reuses cached items without extracting them
+		if key == cachedOverlay.Sha256 {
+			return key, nil
+		}
+		return key, extractor.Extract(filepath.Join(tempDir, "extracted", key), fs.NewLocalFS())
+	}).Times(3)
+
+	var events []ProgressEvent
+	bundleStream := &recordingReadSeeker{ReadSeeker: bytes.NewReader(bundleBytes)}
+	progress := newProgressTracker(ProgressObserverFunc(func(event ProgressEvent) {
+		events = append(events, event)
+	}), time.Hour, int64(len(bundleBytes)))
+	processor := newBundleProcessorV2(processorConfig{workers: 1})
+	b, extractErr := processor.extract(context.Background(), newProgressReadSeeker(bundleStream, progress), mockBundleStore, "", "", ExtractOptions{})
+	progress.finish()
+
+	assert.Nil(t, extractErr)
+	assert.Equal(t, 3, len(b.(*bundle).itemKeys))
+	for _, offset := range bundleStream.readOffsets {
+		assert.False(t, offset >= int64(cachedOverlay.Offset) && offset < int64(cachedOverlay.Offset+cachedOverlay.Size))
+	}
+	assert.Equal(t, int64(len(bundleBytes)-int(cachedOverlay.Size)), progress.totalBytes)
+	assert.Equal(t, float32(100), events[len(events)-1].PercentDone())
+}
+
+func TestBundleProcessorV2_Extract_WithPreserveOptions_ShouldNotReuseOverlaysExtractedWithout(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tempDir, _ := ioutil.TempDir("", "v2-processor")
+	defer os.RemoveAll(tempDir)
+	bundleBytes := writeMultiOverlayBundle(t, tempDir, 1)
+	overlay := overlaysOf(t, bundleBytes)[0]
+	options := ExtractOptions{PreserveModTimes: true}
+
+	// the overlay is only cached with the default options
+	mockBundleStore := NewMockCache(ctrl)
+	mockBundleStore.EXPECT().Exists(gomock.Any()).DoAndReturn(func(key string) bool {
+		return key == overlay.Sha256
+	}).Times(1)
+	mockBundleStore.EXPECT().Put(itemKey(overlay.Sha256, options), gomock.Any()).DoAndReturn(func(key string, extractor Extractor) (string, error) {
+		return key, extractor.Extract(filepath.Join(tempDir, "extracted", key), fs.NewLocalFS())
+	}).Times(1)
+
+	processor := newBundleProcessorV2(processorConfig{workers: 1})
+	b, extractErr := processor.extract(context.Background(), bytes.NewReader(bundleBytes), mockBundleStore, "", "", options)
+
+	assert.Nil(t, extractErr)
+	assert.Equal(t, []string{overlay.Sha256 + "-preserve-modtimes"}, b.(*bundle).itemKeys)
+}
+
+// countingStreamer streams local files for counting:// URLs and counts the streams it opened
+type countingStreamer struct {
+	streams int32
+}
+
+const countingScheme = "counting://"
+
+var testCountingStreamer = &countingStreamer{}
+
+func init() {
+	stream.RegisterStreamer(testCountingStreamer)
+}
+
+func (s *countingStreamer) CanStream(url string) bool {
+	return strings.HasPrefix(url, countingScheme)
+}
+
+func (s *countingStreamer) CreateStream(url string) (io.ReadSeeker, int64, string, error) {
+	atomic.AddInt32(&s.streams, 1)
+	return stream.URLToStream(strings.TrimPrefix(url, countingScheme))
+}
+
+func TestBundleProcessorV2_Extract_WithWorkersAndCachedOverlays_ShouldNotStreamThem(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tempDir, _ := ioutil.TempDir("", "v2-processor")
+	defer os.RemoveAll(tempDir)
+	bundleBytes := writeMultiOverlayBundle(t, tempDir, 3)
+	bundlePath := filepath.Join(tempDir, "bundle.tar")
+	ioutil.WriteFile(bundlePath, bundleBytes, 0644)
+	overlays := overlaysOf(t, bundleBytes)
+
+	mockBundleStore := NewMockCache(ctrl)
+	mockBundleStore.EXPECT().Exists(gomock.Any()).DoAndReturn(func(key string) bool {
+		return key != overlays[2].Sha256
+	}).Times(3)
+	mockBundleStore.EXPECT().Put(gomock.Any(), gomock.Any()).DoAndReturn(func(key string, extractor Extractor) (string, error) {
+		if key != overlays[2].Sha256 {
+			return key, nil
+		}
+		return key, extractor.Extract(filepath.Join(tempDir, "extracted", key), fs.NewLocalFS())
+	}).Times(3)
+
+	source := countingScheme + bundlePath
+	bundleStream, _, contentID, streamErr := stream.URLToStream(source)
+	assert.Nil(t, streamErr)
+	processor := newBundleProcessorV2(processorConfig{workers: 2})
+	_, extractErr := processor.extract(context.Background(), struct{ io.ReadSeeker }{bundleStream}, mockBundleStore, source, contentID, ExtractOptions{})
+
+	assert.Nil(t, extractErr)
+	// the bundle stream, and the stream of the only overlay which isn't cached
+	assert.Equal(t, int32(2), atomic.LoadInt32(&testCountingStreamer.streams))
+}
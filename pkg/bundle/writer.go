@@ -0,0 +1,332 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/3p/archiver"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	defaultOverlayFileSuffix = ".tar.gz"
+	tarBlockSize             = 512
+	// the metadata size changes the offsets written in the metadata, it almost always settles on the second try
+	maxMetadataLayoutAttempts = 10
+)
+
+// Writer creates v2 bundles, in the format read by GetBundle, out of overlays.
+type Writer interface {
+	// Write outputs a v2 bundle containing one overlay per entry of overlayPaths.
+	// An overlay path is either a directory whose contents become the overlay,
+	// or a tarball in a format supported by archiver (.tar, .tar.gz, ...) used as is.
+	// Overlays are sourced in the order they are given.
+	Write(output io.Writer, overlayPaths []string) error
+
+	// Make creates a v2 bundle at bundlePath, see Write
+	Make(bundlePath string, overlayPaths []string) error
+}
+
+// WriterOption configures a Writer created by NewWriter
+type WriterOption func(*v2Writer)
+
+// WithOverlayFileSuffix sets the archive format of the overlays created from directories
+// by its file suffix, for example ".tar.zst". It defaults to ".tar.gz".
+func WithOverlayFileSuffix(suffix string) WriterOption {
+	return func(w *v2Writer) {
+		w.overlayFileSuffix = suffix
+	}
+}
+
+// NewWriter creates a Writer, directories are archived into temporary files while the bundle is written
+func NewWriter(options ...WriterOption) Writer {
+	w := &v2Writer{
+		overlayFileSuffix: defaultOverlayFileSuffix,
+	}
+	for _, option := range options {
+		option(w)
+	}
+	return w
+}
+
+type v2Writer struct {
+	overlayFileSuffix string
+}
+
+// overlayTarball is an overlay tarball ready to be written into a bundle
+type overlayTarball struct {
+	header *tar.Header
+	path   string
+	sha256 string
+}
+
+func (w *v2Writer) Make(bundlePath string, overlayPaths []string) error {
+	out, err := os.Create(bundlePath)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", bundlePath, err)
+	}
+	defer out.Close()
+
+	if writeErr := w.Write(out, overlayPaths); writeErr != nil {
+		return writeErr
+	}
+	return out.Close()
+}
+
+func (w *v2Writer) Write(output io.Writer, overlayPaths []string) error {
+	if len(overlayPaths) == 0 {
+		return fmt.Errorf("a bundle needs at least one overlay")
+	}
+
+	overlayFormat := archiver.MatchingFormat(w.overlayFileSuffix)
+	if overlayFormat == nil {
+		return fmt.Errorf("overlay file suffix %s is not a supported archive format", w.overlayFileSuffix)
+	}
+
+	tempDir, tempErr := ioutil.TempDir("", "bundle-writer")
+	if tempErr != nil {
+		return tempErr
+	}
+	defer os.RemoveAll(tempDir)
+
+	var tarballs []overlayTarball
+	for i, overlayPath := range overlayPaths {
+		archivePath := filepath.Join(tempDir, fmt.Sprintf("%d%s", i, w.overlayFileSuffix))
+		tarball, overlayErr := w.prepareOverlay(overlayPath, overlayFormat, archivePath)
+		if overlayErr != nil {
+			return overlayErr
+		}
+		tarballs = append(tarballs, tarball)
+	}
+
+	versionHeader := newFileHeader(versionFileName, int64(len(processorVersion2)))
+	metadata, layoutErr := layoutMetadata(versionHeader, tarballs)
+	if layoutErr != nil {
+		return layoutErr
+	}
+	metadataHeader := newFileHeader(v2MetadataFileName, int64(len(metadata)))
+
+	// write the bundle, counting bytes to make sure the overlays end up at the offsets in the metadata
+	counter := &countingWriter{w: output}
+	tarWriter := tar.NewWriter(counter)
+	if err := writeTarEntry(tarWriter, versionHeader, bytes.NewReader([]byte(processorVersion2))); err != nil {
+		return err
+	}
+	if err := writeTarEntry(tarWriter, metadataHeader, bytes.NewReader(metadata)); err != nil {
+		return err
+	}
+
+	expectedOffsets := overlayOffsets(versionHeader, metadataHeader, tarballs)
+	for i, tarball := range tarballs {
+		if err := tarWriter.WriteHeader(tarball.header); err != nil {
+			return err
+		}
+		if counter.count != expectedOffsets[i] {
+			return fmt.Errorf("overlay %s written at offset %d instead of %d", tarball.header.Name, counter.count, expectedOffsets[i])
+		}
+		if err := copyFile(tarWriter, tarball.path); err != nil {
+			return err
+		}
+	}
+	return tarWriter.Close()
+}
+
+// prepareOverlay archives overlayPath into archivePath with format if it is a directory, and hashes the overlay tarball
+func (w *v2Writer) prepareOverlay(overlayPath string, format archiver.Archiver, archivePath string) (overlayTarball, error) {
+	info, statErr := os.Stat(overlayPath)
+	if statErr != nil {
+		return overlayTarball{}, statErr
+	}
+
+	tarballPath := overlayPath
+	name := filepath.Base(overlayPath)
+	if info.IsDir() {
+		if archiveErr := archiveDirectoryContents(overlayPath, format, archivePath); archiveErr != nil {
+			return overlayTarball{}, archiveErr
+		}
+		tarballPath = archivePath
+		name += w.overlayFileSuffix
+	} else if archiver.MatchingFormat(overlayPath) == nil {
+		return overlayTarball{}, fmt.Errorf("overlay %s is neither a directory nor a supported archive", overlayPath)
+	}
+
+	sha256Sum, size, hashErr := hashFile(tarballPath)
+	if hashErr != nil {
+		return overlayTarball{}, hashErr
+	}
+
+	return overlayTarball{
+		header: newFileHeader(name, size),
+		path:   tarballPath,
+		sha256: sha256Sum,
+	}, nil
+}
+
+// archiveDirectoryContents creates an archive of the contents of directory, without the directory itself
+func archiveDirectoryContents(directory string, format archiver.Archiver, archivePath string) error {
+	entries, readErr := ioutil.ReadDir(directory)
+	if readErr != nil {
+		return readErr
+	}
+
+	var filePaths []string
+	for _, entry := range entries {
+		filePaths = append(filePaths, filepath.Join(directory, entry.Name()))
+	}
+	return format.Make(archivePath, filePaths)
+}
+
+// layoutMetadata creates the metadata.tar.gz of a bundle, the offsets of the overlays
+// it lists depend on its own size so it's created until its size stops changing them
+func layoutMetadata(versionHeader *tar.Header, tarballs []overlayTarball) ([]byte, error) {
+	var metadata []byte
+	for attempt := 0; attempt < maxMetadataLayoutAttempts; attempt++ {
+		metadataHeader := newFileHeader(v2MetadataFileName, int64(len(metadata)))
+		offsets := overlayOffsets(versionHeader, metadataHeader, tarballs)
+
+		newMetadata, metadataErr := createMetadata(tarballs, offsets)
+		if metadataErr != nil {
+			return nil, metadataErr
+		}
+
+		newMetadataHeader := newFileHeader(v2MetadataFileName, int64(len(newMetadata)))
+		if metadata != nil && sameOffsets(offsets, overlayOffsets(versionHeader, newMetadataHeader, tarballs)) {
+			return newMetadata, nil
+		}
+		metadata = newMetadata
+	}
+	return nil, fmt.Errorf("unable to lay out the bundle metadata")
+}
+
+// createMetadata creates a metadata.tar.gz holding the overlays.json of tarballs at offsets
+func createMetadata(tarballs []overlayTarball, offsets []int64) ([]byte, error) {
+	var bundleOverlays overlays
+	for i, tarball := range tarballs {
+		bundleOverlays.Overlays = append(bundleOverlays.Overlays, overlay{
+			FileName: tarball.header.Name,
+			Sha256:   tarball.sha256,
+			Offset:   int(offsets[i]),
+			Size:     int(tarball.header.Size),
+		})
+	}
+
+	overlaysJSON, jsonErr := json.Marshal(bundleOverlays)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	tempDir, tempErr := ioutil.TempDir("", "bundle-metadata")
+	if tempErr != nil {
+		return nil, tempErr
+	}
+	defer os.RemoveAll(tempDir)
+
+	overlaysPath := filepath.Join(tempDir, overlaysFileName)
+	if writeErr := ioutil.WriteFile(overlaysPath, overlaysJSON, 0644); writeErr != nil {
+		return nil, writeErr
+	}
+
+	var metadata bytes.Buffer
+	if archiveErr := archiver.TarGz.Write(&metadata, []string{overlaysPath}); archiveErr != nil {
+		return nil, archiveErr
+	}
+	return metadata.Bytes(), nil
+}
+
+// overlayOffsets computes where the contents of the overlays start in a bundle
+func overlayOffsets(versionHeader *tar.Header, metadataHeader *tar.Header, tarballs []overlayTarball) []int64 {
+	offset := tarEntrySize(versionHeader) + tarEntrySize(metadataHeader)
+
+	var offsets []int64
+	for _, tarball := range tarballs {
+		offsets = append(offsets, offset+tarHeaderSize(tarball.header))
+		offset += tarEntrySize(tarball.header)
+	}
+	return offsets
+}
+
+func sameOffsets(a []int64, b []int64) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// tarEntrySize is the number of bytes taken by the header and the padded contents of an entry
+func tarEntrySize(header *tar.Header) int64 {
+	return tarHeaderSize(header) + (header.Size+tarBlockSize-1)/tarBlockSize*tarBlockSize
+}
+
+// tarHeaderSize measures the header blocks of an entry, long names take more than one block
+func tarHeaderSize(header *tar.Header) int64 {
+	counter := &countingWriter{w: ioutil.Discard}
+	tar.NewWriter(counter).WriteHeader(header)
+	return counter.count
+}
+
+func newFileHeader(name string, size int64) *tar.Header {
+	return &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0644,
+		ModTime:  time.Unix(0, 0),
+	}
+}
+
+func writeTarEntry(tarWriter *tar.Writer, header *tar.Header, contents io.Reader) error {
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.Copy(tarWriter, contents)
+	return err
+}
+
+func copyFile(output io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(output, file)
+	return err
+}
+
+func hashFile(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+type countingWriter struct {
+	w     io.Writer
+	count int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.count += int64(n)
+	return n, err
+}
@@ -0,0 +1,158 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/3p/archiver"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// extracts the bundle with a mock cache extracting every item into extractRoot/<key>
+func extractWrittenBundle(t *testing.T, ctrl *gomock.Controller, bundleBytes []byte, extractRoot string) []string {
+	var keys []string
+	mockBundleStore := NewMockCache(ctrl)
+	mockBundleStore.EXPECT().Exists(gomock.Any()).Return(false).AnyTimes()
+	mockBundleStore.EXPECT().Put(gomock.Any(), gomock.Any()).DoAndReturn(func(key string, extractor Extractor) (string, error) {
+		keys = append(keys, key)
+		itemPath := filepath.Join(extractRoot, key)
+		return itemPath, extractor.Extract(itemPath, fs.NewLocalFS())
+	}).AnyTimes()
+
+	archive, archiveErr := newBundleArchive(bytes.NewReader(bundleBytes), "", "", processorConfig{})
+	assert.Nil(t, archiveErr)
+	assert.Equal(t, processorVersion2, archive.Version())
+
+	_, extractErr := archive.Extract(context.Background(), mockBundleStore, ExtractOptions{})
+	assert.Nil(t, extractErr)
+	return keys
+}
+
+func TestWriter_Write_ShouldCreateReadableV2Bundle(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tempDir, _ := ioutil.TempDir("", "writer")
+	defer os.RemoveAll(tempDir)
+
+	// a directory overlay, with a nested directory
+	directoryOverlay := filepath.Join(tempDir, "workspace")
+	os.MkdirAll(filepath.Join(directoryOverlay, "lib"), 0755)
+	ioutil.WriteFile(filepath.Join(directoryOverlay, "setup.sh"), []byte("workspace"), 0644)
+	ioutil.WriteFile(filepath.Join(directoryOverlay, "lib", "library.so"), []byte("library"), 0644)
+
+	// a tarball overlay
+	tarballOverlay := filepath.Join(tempDir, overlayFileName)
+	overlay := createTestOverlay(t)
+	ioutil.WriteFile(tarballOverlay, overlay, 0644)
+
+	var bundleBytes bytes.Buffer
+	writeErr := NewWriter().Write(&bundleBytes, []string{directoryOverlay, tarballOverlay})
+	assert.Nil(t, writeErr)
+
+	extractRoot := filepath.Join(tempDir, "extracted")
+	keys := extractWrittenBundle(t, ctrl, bundleBytes.Bytes(), extractRoot)
+
+	assert.Equal(t, 2, len(keys))
+	assert.Equal(t, sha256Hex(overlay), keys[1])
+	content, _ := ioutil.ReadFile(filepath.Join(extractRoot, keys[0], "setup.sh"))
+	assert.Equal(t, "workspace", string(content))
+	content, _ = ioutil.ReadFile(filepath.Join(extractRoot, keys[0], "lib", "library.so"))
+	assert.Equal(t, "library", string(content))
+	content, _ = ioutil.ReadFile(filepath.Join(extractRoot, keys[1], "setup.sh"))
+	assert.Equal(t, overlayFileContent, string(content))
+}
+
+func TestWriter_Make_WithLongOverlayName_ShouldCreateReadableV2Bundle(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tempDir, _ := ioutil.TempDir("", "writer")
+	defer os.RemoveAll(tempDir)
+
+	// names over 100 characters need extra tar header blocks
+	tarballOverlay := filepath.Join(tempDir, strings.Repeat("o", 150)+".tar.gz")
+	ioutil.WriteFile(tarballOverlay, createTestOverlay(t), 0644)
+
+	bundlePath := filepath.Join(tempDir, "bundle.tar")
+	assert.Nil(t, NewWriter().Make(bundlePath, []string{tarballOverlay}))
+
+	bundleBytes, _ := ioutil.ReadFile(bundlePath)
+	keys := extractWrittenBundle(t, ctrl, bundleBytes, filepath.Join(tempDir, "extracted"))
+
+	assert.Equal(t, 1, len(keys))
+	content, _ := ioutil.ReadFile(filepath.Join(tempDir, "extracted", keys[0], "setup.sh"))
+	assert.Equal(t, overlayFileContent, string(content))
+}
+
+func TestWriter_Write_WithUnsupportedOverlay_ShouldReturnError(t *testing.T) {
+	t.Parallel()
+	tempDir, _ := ioutil.TempDir("", "writer")
+	defer os.RemoveAll(tempDir)
+
+	overlayPath := filepath.Join(tempDir, "overlay.txt")
+	ioutil.WriteFile(overlayPath, []byte("not an archive"), 0644)
+
+	assert.NotNil(t, NewWriter().Write(ioutil.Discard, []string{overlayPath}))
+	assert.NotNil(t, NewWriter().Write(ioutil.Discard, []string{filepath.Join(tempDir, "missing")}))
+	assert.NotNil(t, NewWriter().Write(ioutil.Discard, nil))
+}
+
+func TestWriter_Write_WithCompressedOverlays_ShouldCreateReadableV2Bundle(t *testing.T) {
+	t.Parallel()
+	for _, suffix := range []string{".tar.zst", ".tar.xz", ".tar.bz2", ".zip"} {
+		func() {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			tempDir, _ := ioutil.TempDir("", "writer")
+			defer os.RemoveAll(tempDir)
+
+			directoryOverlay := filepath.Join(tempDir, "workspace")
+			os.MkdirAll(directoryOverlay, 0755)
+			ioutil.WriteFile(filepath.Join(directoryOverlay, "setup.sh"), []byte("workspace"), 0644)
+
+			// a tarball overlay in the same format, used as is
+			tarballOverlay := filepath.Join(tempDir, "dependencies"+suffix)
+			assert.Nil(t, archiver.MatchingFormat(suffix).Make(tarballOverlay, []string{filepath.Join(directoryOverlay, "setup.sh")}))
+
+			var bundleBytes bytes.Buffer
+			writer := NewWriter(WithOverlayFileSuffix(suffix))
+			assert.Nil(t, writer.Write(&bundleBytes, []string{directoryOverlay, tarballOverlay}))
+
+			manifest, manifestErr := readManifest(bytes.NewReader(bundleBytes.Bytes()))
+			assert.Nil(t, manifestErr)
+			assert.Equal(t, "workspace"+suffix, manifest.Overlays[0].Name)
+			assert.Equal(t, "dependencies"+suffix, manifest.Overlays[1].Name)
+
+			extractRoot := filepath.Join(tempDir, "extracted")
+			keys := extractWrittenBundle(t, ctrl, bundleBytes.Bytes(), extractRoot)
+
+			assert.Equal(t, 2, len(keys), suffix)
+			for _, key := range keys {
+				content, _ := ioutil.ReadFile(filepath.Join(extractRoot, key, "setup.sh"))
+				assert.Equal(t, "workspace", string(content), suffix)
+			}
+		}()
+	}
+}
+
+func TestWriter_Write_WithUnsupportedOverlayFileSuffix_ShouldReturnError(t *testing.T) {
+	t.Parallel()
+	tempDir, _ := ioutil.TempDir("", "writer")
+	defer os.RemoveAll(tempDir)
+
+	writer := NewWriter(WithOverlayFileSuffix(".rar"))
+	assert.NotNil(t, writer.Write(ioutil.Discard, []string{tempDir}))
+}
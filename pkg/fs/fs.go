@@ -21,6 +21,16 @@ type FileSystem interface {
 	MkdirAll(name string, mode FileMode) error
 	ReadFile(filename string) ([]byte, error)
 	WriteFile(filename string, data []byte, mode FileMode) error
+	Rename(oldpath, newpath string) error
+	ReadDir(dirname string) ([]os.FileInfo, error)
+	Remove(name string) error
+	Lstat(name string) (FileInfo, error)
+	Symlink(oldname, newname string) error
+	Link(oldname, newname string) error
+	Chmod(name string, mode FileMode) error
+	Chtimes(name string, atime time.Time, mtime time.Time) error
+	Lchown(name string, uid, gid int) error
+	Setxattr(name string, attr string, data []byte) error
 }
 
 // File provides a mockable interface for os file operations
@@ -56,13 +66,30 @@ type FileMode os.FileMode
 // osFS implements FileSystem using the local disk.
 type osFS struct{}
 
-func (osFS) NewFile(fd uintptr, name string) File      { return os.NewFile(fd, name) }
-func (osFS) Create(name string) (File, error)          { return os.Create(name) }
-func (osFS) Open(name string) (File, error)            { return os.Open(name) }
-func (osFS) Stat(name string) (FileInfo, error)        { return os.Stat(name) }
-func (osFS) RemoveAll(name string) error               { return os.RemoveAll(name) }
-func (osFS) MkdirAll(name string, mode FileMode) error { return os.MkdirAll(name, os.FileMode(mode)) }
-func (osFS) ReadFile(filename string) ([]byte, error)  { return ioutil.ReadFile(filename) }
+func (osFS) NewFile(fd uintptr, name string) File          { return os.NewFile(fd, name) }
+func (osFS) Create(name string) (File, error)              { return os.Create(name) }
+func (osFS) Open(name string) (File, error)                { return os.Open(name) }
+func (osFS) Stat(name string) (FileInfo, error)            { return os.Stat(name) }
+func (osFS) RemoveAll(name string) error                   { return os.RemoveAll(name) }
+func (osFS) MkdirAll(name string, mode FileMode) error     { return os.MkdirAll(name, os.FileMode(mode)) }
+func (osFS) ReadFile(filename string) ([]byte, error)      { return ioutil.ReadFile(filename) }
+func (osFS) Rename(oldpath, newpath string) error          { return os.Rename(oldpath, newpath) }
+func (osFS) ReadDir(dirname string) ([]os.FileInfo, error) { return ioutil.ReadDir(dirname) }
+func (osFS) Remove(name string) error                      { return os.Remove(name) }
+func (osFS) Lstat(name string) (FileInfo, error)           { return os.Lstat(name) }
+func (osFS) Symlink(oldname, newname string) error         { return os.Symlink(oldname, newname) }
+func (osFS) Link(oldname, newname string) error            { return os.Link(oldname, newname) }
+func (osFS) Chmod(name string, mode FileMode) error        { return os.Chmod(name, os.FileMode(mode)) }
+func (osFS) Lchown(name string, uid, gid int) error        { return os.Lchown(name, uid, gid) }
+func (osFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+func (osFS) Setxattr(name string, attr string, data []byte) error {
+	if err := setxattr(name, attr, data); err != nil {
+		return &os.PathError{Op: "setxattr", Path: name, Err: err}
+	}
+	return nil
+}
 func (osFS) WriteFile(filename string, data []byte, mode FileMode) error {
 	return ioutil.WriteFile(filename, data, os.FileMode(mode))
 }
@@ -0,0 +1,636 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// how many symbolic links are followed while resolving a path before giving up, like Linux
+const maxSymlinkHops = 40
+
+// NewMemFS creates a FileSystem interface that keeps
+// files in memory, for tests and dry runs.
+// Paths are absolute, relative paths are resolved from the root.
+func NewMemFS() FileSystem {
+	return &memFS{
+		nodes: map[string]*memNode{
+			string(filepath.Separator): {mode: os.ModeDir | 0755, modTime: time.Now()},
+		},
+	}
+}
+
+// memNode is a file, directory or symbolic link. Hard links share the same memNode.
+type memNode struct {
+	mode    os.FileMode
+	data    []byte
+	target  string
+	modTime time.Time
+	uid     int
+	gid     int
+	xattrs  map[string][]byte
+}
+
+// memFS implements FileSystem in memory, nodes are indexed by their clean absolute path.
+type memFS struct {
+	mutex sync.Mutex
+	nodes map[string]*memNode
+}
+
+func cleanPath(name string) string {
+	return filepath.Clean(filepath.Join(string(filepath.Separator), name))
+}
+
+// isInside is true when name is a path in directory
+func isInside(name string, directory string) bool {
+	return strings.HasPrefix(name, strings.TrimSuffix(directory, string(filepath.Separator))+string(filepath.Separator))
+}
+
+func pathError(op string, name string, err error) error {
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+// resolve follows the symbolic links in name, and the last element of name too when followLast is set.
+// It returns the resolved path, which may not exist, when its parent directory exists.
+func (m *memFS) resolve(name string, followLast bool) (string, error) {
+	remaining := strings.Split(strings.TrimPrefix(cleanPath(name), string(filepath.Separator)), string(filepath.Separator))
+	resolved := string(filepath.Separator)
+	hops := 0
+
+	for len(remaining) > 0 {
+		element := remaining[0]
+		remaining = remaining[1:]
+		if element == "" || element == "." {
+			continue
+		}
+		if element == ".." {
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		current := filepath.Join(resolved, element)
+		node, exists := m.nodes[current]
+		if !exists {
+			if len(remaining) > 0 {
+				return "", os.ErrNotExist
+			}
+			return current, nil
+		}
+
+		if node.mode&os.ModeSymlink != 0 && (len(remaining) > 0 || followLast) {
+			hops++
+			if hops > maxSymlinkHops {
+				return "", syscall.ELOOP
+			}
+			target := node.target
+			if filepath.IsAbs(target) {
+				resolved = string(filepath.Separator)
+			}
+			remaining = append(strings.Split(filepath.Clean(target), string(filepath.Separator)), remaining...)
+			continue
+		}
+
+		if len(remaining) > 0 && !node.mode.IsDir() {
+			return "", syscall.ENOTDIR
+		}
+		resolved = current
+	}
+	return resolved, nil
+}
+
+// lookup resolves name and returns its node, or an error if it doesn't exist
+func (m *memFS) lookup(op string, name string, followLast bool) (string, *memNode, error) {
+	resolved, resolveErr := m.resolve(name, followLast)
+	if resolveErr != nil {
+		return "", nil, pathError(op, name, resolveErr)
+	}
+	node, exists := m.nodes[resolved]
+	if !exists {
+		return "", nil, pathError(op, name, os.ErrNotExist)
+	}
+	return resolved, node, nil
+}
+
+// create adds a node at name, whose parent directory must exist, and which must not exist
+func (m *memFS) create(op string, name string, node *memNode) (string, error) {
+	resolved, resolveErr := m.resolve(name, false)
+	if resolveErr != nil {
+		return "", pathError(op, name, resolveErr)
+	}
+	if _, exists := m.nodes[resolved]; exists {
+		return "", pathError(op, name, os.ErrExist)
+	}
+	if parent, exists := m.nodes[filepath.Dir(resolved)]; !exists || !parent.mode.IsDir() {
+		return "", pathError(op, name, os.ErrNotExist)
+	}
+	m.nodes[resolved] = node
+	return resolved, nil
+}
+
+func (m *memFS) NewFile(fd uintptr, name string) File {
+	// there are no file descriptors in memory
+	return nil
+}
+
+func (m *memFS) Create(name string) (File, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, node, lookupErr := m.lookup("open", name, true)
+	if lookupErr == nil {
+		if node.mode.IsDir() {
+			return nil, pathError("open", name, syscall.EISDIR)
+		}
+		node.data = nil
+		node.modTime = time.Now()
+		return &memFile{fs: m, node: node, name: name, writable: true}, nil
+	}
+
+	resolved, resolveErr := m.resolve(name, true)
+	if resolveErr != nil {
+		return nil, pathError("open", name, resolveErr)
+	}
+	node = &memNode{mode: 0666, modTime: time.Now()}
+	if _, createErr := m.create("open", resolved, node); createErr != nil {
+		return nil, createErr
+	}
+	return &memFile{fs: m, node: node, name: name, writable: true}, nil
+}
+
+func (m *memFS) Open(name string) (File, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, node, lookupErr := m.lookup("open", name, true)
+	if lookupErr != nil {
+		return nil, lookupErr
+	}
+	return &memFile{fs: m, node: node, name: name}, nil
+}
+
+func (m *memFS) Stat(name string) (FileInfo, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	resolved, node, lookupErr := m.lookup("stat", name, true)
+	if lookupErr != nil {
+		return nil, lookupErr
+	}
+	return newMemFileInfo(resolved, node), nil
+}
+
+func (m *memFS) Lstat(name string) (FileInfo, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	resolved, node, lookupErr := m.lookup("lstat", name, false)
+	if lookupErr != nil {
+		return nil, lookupErr
+	}
+	return newMemFileInfo(resolved, node), nil
+}
+
+func (m *memFS) RemoveAll(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	resolved, resolveErr := m.resolve(name, false)
+	if resolveErr != nil {
+		// a path under something missing or which isn't a directory doesn't exist
+		return nil
+	}
+	if resolved == string(filepath.Separator) {
+		return pathError("unlinkat", name, syscall.EBUSY)
+	}
+	for nodePath := range m.nodes {
+		if nodePath == resolved || isInside(nodePath, resolved) {
+			delete(m.nodes, nodePath)
+		}
+	}
+	return nil
+}
+
+func (m *memFS) Remove(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	resolved, node, lookupErr := m.lookup("remove", name, false)
+	if lookupErr != nil {
+		return lookupErr
+	}
+	if node.mode.IsDir() {
+		for nodePath := range m.nodes {
+			if isInside(nodePath, resolved) {
+				return pathError("remove", name, syscall.ENOTEMPTY)
+			}
+		}
+	}
+	delete(m.nodes, resolved)
+	return nil
+}
+
+func (m *memFS) MkdirAll(name string, mode FileMode) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// create the missing directories one element at a time, the existing ones may be symbolic links
+	elements := strings.Split(cleanPath(name), string(filepath.Separator))
+	for i := 2; i <= len(elements); i++ {
+		resolved, resolveErr := m.resolve(strings.Join(elements[:i], string(filepath.Separator)), true)
+		if resolveErr != nil {
+			return pathError("mkdir", name, resolveErr)
+		}
+
+		if node, exists := m.nodes[resolved]; exists {
+			if !node.mode.IsDir() {
+				return pathError("mkdir", name, syscall.ENOTDIR)
+			}
+			continue
+		}
+		m.nodes[resolved] = &memNode{mode: os.ModeDir | os.FileMode(mode).Perm(), modTime: time.Now()}
+	}
+	return nil
+}
+
+func (m *memFS) ReadFile(filename string) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, node, lookupErr := m.lookup("open", filename, true)
+	if lookupErr != nil {
+		return nil, lookupErr
+	}
+	if node.mode.IsDir() {
+		return nil, pathError("read", filename, syscall.EISDIR)
+	}
+	return append([]byte(nil), node.data...), nil
+}
+
+func (m *memFS) WriteFile(filename string, data []byte, mode FileMode) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// like ioutil.WriteFile, the mode only applies to new files
+	_, node, lookupErr := m.lookup("open", filename, true)
+	if lookupErr != nil {
+		resolved, resolveErr := m.resolve(filename, true)
+		if resolveErr != nil {
+			return pathError("open", filename, resolveErr)
+		}
+		node = &memNode{mode: os.FileMode(mode).Perm()}
+		if _, createErr := m.create("open", resolved, node); createErr != nil {
+			return createErr
+		}
+	} else if node.mode.IsDir() {
+		return pathError("open", filename, syscall.EISDIR)
+	}
+
+	node.data = append([]byte(nil), data...)
+	node.modTime = time.Now()
+	return nil
+}
+
+func (m *memFS) Rename(oldpath, newpath string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	oldResolved, node, lookupErr := m.lookup("rename", oldpath, false)
+	if lookupErr != nil {
+		return lookupErr
+	}
+	newResolved, resolveErr := m.resolve(newpath, false)
+	if resolveErr != nil {
+		return pathError("rename", newpath, resolveErr)
+	}
+	if oldResolved == newResolved {
+		return nil
+	}
+	if isInside(newResolved, oldResolved) {
+		return pathError("rename", newpath, syscall.EINVAL)
+	}
+	if parent, exists := m.nodes[filepath.Dir(newResolved)]; !exists || !parent.mode.IsDir() {
+		return pathError("rename", newpath, os.ErrNotExist)
+	}
+
+	// like rename(2), an existing file is replaced, and an existing directory only by a directory if it's empty
+	if existing, exists := m.nodes[newResolved]; exists {
+		if existing.mode.IsDir() != node.mode.IsDir() {
+			return pathError("rename", newpath, os.ErrExist)
+		}
+		for nodePath := range m.nodes {
+			if isInside(nodePath, newResolved) {
+				return pathError("rename", newpath, syscall.ENOTEMPTY)
+			}
+		}
+	}
+
+	moved := map[string]*memNode{newResolved: node}
+	delete(m.nodes, oldResolved)
+	for nodePath, child := range m.nodes {
+		if isInside(nodePath, oldResolved) {
+			moved[filepath.Join(newResolved, strings.TrimPrefix(nodePath, oldResolved))] = child
+			delete(m.nodes, nodePath)
+		}
+	}
+	for nodePath, movedNode := range moved {
+		m.nodes[nodePath] = movedNode
+	}
+	return nil
+}
+
+func (m *memFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	resolved, node, lookupErr := m.lookup("open", dirname, true)
+	if lookupErr != nil {
+		return nil, lookupErr
+	}
+	if !node.mode.IsDir() {
+		return nil, pathError("readdirent", dirname, syscall.ENOTDIR)
+	}
+
+	var infos []os.FileInfo
+	for nodePath, child := range m.nodes {
+		if nodePath != resolved && filepath.Dir(nodePath) == resolved {
+			infos = append(infos, newMemFileInfo(nodePath, child))
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+func (m *memFS) Symlink(oldname, newname string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	node := &memNode{mode: os.ModeSymlink | 0777, target: oldname, modTime: time.Now()}
+	_, createErr := m.create("symlink", newname, node)
+	return createErr
+}
+
+func (m *memFS) Link(oldname, newname string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, node, lookupErr := m.lookup("link", oldname, false)
+	if lookupErr != nil {
+		return lookupErr
+	}
+	if node.mode.IsDir() {
+		return pathError("link", oldname, syscall.EPERM)
+	}
+	_, createErr := m.create("link", newname, node)
+	return createErr
+}
+
+func (m *memFS) Chmod(name string, mode FileMode) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, node, lookupErr := m.lookup("chmod", name, true)
+	if lookupErr != nil {
+		return lookupErr
+	}
+	node.mode = node.mode&os.ModeType | os.FileMode(mode).Perm()
+	return nil
+}
+
+func (m *memFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, node, lookupErr := m.lookup("chtimes", name, true)
+	if lookupErr != nil {
+		return lookupErr
+	}
+	node.modTime = mtime
+	return nil
+}
+
+func (m *memFS) Lchown(name string, uid, gid int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, node, lookupErr := m.lookup("lchown", name, false)
+	if lookupErr != nil {
+		return lookupErr
+	}
+	node.uid = uid
+	node.gid = gid
+	return nil
+}
+
+func (m *memFS) Setxattr(name string, attr string, data []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, node, lookupErr := m.lookup("setxattr", name, true)
+	if lookupErr != nil {
+		return lookupErr
+	}
+	if node.xattrs == nil {
+		node.xattrs = map[string][]byte{}
+	}
+	node.xattrs[attr] = append([]byte(nil), data...)
+	return nil
+}
+
+// memFile is an open file of a memFS, it reads and writes the data of its node
+type memFile struct {
+	fs       *memFS
+	node     *memNode
+	name     string
+	offset   int64
+	writable bool
+	closed   bool
+}
+
+var errMemFileClosed = errors.New("file already closed")
+
+func (f *memFile) check(op string, write bool) error {
+	if f.closed {
+		return pathError(op, f.name, errMemFileClosed)
+	}
+	if f.node.mode.IsDir() {
+		return pathError(op, f.name, syscall.EISDIR)
+	}
+	if write && !f.writable {
+		return pathError(op, f.name, syscall.EBADF)
+	}
+	return nil
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	if checkErr := f.check("read", false); checkErr != nil {
+		return 0, checkErr
+	}
+	n, readErr := f.readAt(p, f.offset)
+	f.offset += int64(n)
+	return n, readErr
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	if checkErr := f.check("read", false); checkErr != nil {
+		return 0, checkErr
+	}
+	if off < 0 {
+		return 0, pathError("readat", f.name, errors.New("negative offset"))
+	}
+	n, readErr := f.readAt(p, off)
+	if readErr == nil && n < len(p) {
+		readErr = io.EOF
+	}
+	return n, readErr
+}
+
+func (f *memFile) readAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(f.node.data)) {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	return copy(p, f.node.data[off:]), nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	if checkErr := f.check("write", true); checkErr != nil {
+		return 0, checkErr
+	}
+	n := f.writeAt(p, f.offset)
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *memFile) WriteAt(p []byte, off int64) (int, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	if checkErr := f.check("write", true); checkErr != nil {
+		return 0, checkErr
+	}
+	if off < 0 {
+		return 0, pathError("writeat", f.name, errors.New("negative offset"))
+	}
+	return f.writeAt(p, off), nil
+}
+
+func (f *memFile) writeAt(p []byte, off int64) int {
+	if end := off + int64(len(p)); end > int64(len(f.node.data)) {
+		if end > int64(cap(f.node.data)) {
+			grown := make([]byte, end, 2*end)
+			copy(grown, f.node.data)
+			f.node.data = grown
+		} else {
+			f.node.data = f.node.data[:end]
+		}
+	}
+	f.node.modTime = time.Now()
+	return copy(f.node.data[off:], p)
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	if f.closed {
+		return 0, pathError("seek", f.name, errMemFileClosed)
+	}
+	var newOffset int64
+	switch whence {
+	case io.SeekStart:
+		newOffset = offset
+	case io.SeekCurrent:
+		newOffset = f.offset + offset
+	case io.SeekEnd:
+		newOffset = int64(len(f.node.data)) + offset
+	default:
+		return 0, pathError("seek", f.name, syscall.EINVAL)
+	}
+	if newOffset < 0 {
+		return 0, pathError("seek", f.name, syscall.EINVAL)
+	}
+	f.offset = newOffset
+	return newOffset, nil
+}
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	if f.closed {
+		return nil, pathError("stat", f.name, errMemFileClosed)
+	}
+	return newMemFileInfo(f.name, f.node), nil
+}
+
+func (f *memFile) Close() error {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	if f.closed {
+		return pathError("close", f.name, errMemFileClosed)
+	}
+	f.closed = true
+	return nil
+}
+
+// MemSys is what Sys returns for the FileInfo of a file in a memory FileSystem
+type MemSys struct {
+	Uid    int
+	Gid    int
+	Xattrs map[string][]byte
+}
+
+// memFileInfo is a snapshot of a memNode
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+	sys     *MemSys
+}
+
+func newMemFileInfo(name string, node *memNode) *memFileInfo {
+	size := int64(len(node.data))
+	if node.mode&os.ModeSymlink != 0 {
+		size = int64(len(node.target))
+	}
+	xattrs := map[string][]byte{}
+	for attr, data := range node.xattrs {
+		xattrs[attr] = append([]byte(nil), data...)
+	}
+	return &memFileInfo{
+		name:    filepath.Base(name),
+		size:    size,
+		mode:    node.mode,
+		modTime: node.modTime,
+		sys:     &MemSys{Uid: node.uid, Gid: node.gid, Xattrs: xattrs},
+	}
+}
+
+func (i *memFileInfo) Name() string       { return i.name }
+func (i *memFileInfo) Size() int64        { return i.size }
+func (i *memFileInfo) Mode() os.FileMode  { return i.mode }
+func (i *memFileInfo) ModTime() time.Time { return i.modTime }
+func (i *memFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memFileInfo) Sys() interface{}   { return i.sys }
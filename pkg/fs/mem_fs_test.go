@@ -0,0 +1,205 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMemFS_WriteFile_ShouldBeReadable(t *testing.T) {
+	t.Parallel()
+	memFS := NewMemFS()
+
+	assert.Nil(t, memFS.MkdirAll("/root/dir", 0700))
+	assert.Nil(t, memFS.WriteFile("/root/dir/file", []byte("content"), 0600))
+
+	content, readErr := memFS.ReadFile("/root/dir/file")
+	assert.Nil(t, readErr)
+	assert.Equal(t, "content", string(content))
+
+	info, statErr := memFS.Stat("/root/dir/file")
+	assert.Nil(t, statErr)
+	assert.Equal(t, "file", info.Name())
+	assert.Equal(t, int64(7), info.Size())
+	assert.Equal(t, os.FileMode(0600), info.Mode())
+
+	info, statErr = memFS.Stat("/root/dir")
+	assert.Nil(t, statErr)
+	assert.True(t, info.IsDir())
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+}
+
+func TestMemFS_WithMissingPaths_ShouldReturnNotExistErrors(t *testing.T) {
+	t.Parallel()
+	memFS := NewMemFS()
+
+	_, openErr := memFS.Open("/missing")
+	assert.True(t, os.IsNotExist(openErr))
+	_, statErr := memFS.Stat("/missing/file")
+	assert.True(t, os.IsNotExist(statErr))
+	_, createErr := memFS.Create("/missing/file")
+	assert.True(t, os.IsNotExist(createErr))
+	assert.True(t, os.IsNotExist(memFS.WriteFile("/missing/file", nil, 0644)))
+	assert.True(t, os.IsNotExist(memFS.Rename("/missing", "/other")))
+	assert.Nil(t, memFS.RemoveAll("/missing"))
+}
+
+func TestMemFS_File_ShouldReadWriteAndSeek(t *testing.T) {
+	t.Parallel()
+	memFS := NewMemFS()
+
+	file, createErr := memFS.Create("/file")
+	assert.Nil(t, createErr)
+	_, writeErr := file.Write([]byte("hello world"))
+	assert.Nil(t, writeErr)
+	_, writeErr = file.WriteAt([]byte("W"), 6)
+	assert.Nil(t, writeErr)
+
+	offset, seekErr := file.Seek(-5, io.SeekEnd)
+	assert.Nil(t, seekErr)
+	assert.Equal(t, int64(6), offset)
+	content, _ := ioutil.ReadAll(file)
+	assert.Equal(t, "World", string(content))
+
+	buffer := make([]byte, 5)
+	n, readErr := file.ReadAt(buffer, 8)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, io.EOF, readErr)
+	assert.Equal(t, "rld", string(buffer[:n]))
+
+	assert.Nil(t, file.Close())
+	_, readErr = file.Read(buffer)
+	assert.NotNil(t, readErr)
+
+	// files opened for reading can't be written
+	file, _ = memFS.Open("/file")
+	_, writeErr = file.Write([]byte("read only"))
+	assert.NotNil(t, writeErr)
+}
+
+func TestMemFS_Symlink_ShouldBeFollowed(t *testing.T) {
+	t.Parallel()
+	memFS := NewMemFS()
+
+	memFS.MkdirAll("/lib/versions", 0755)
+	memFS.WriteFile("/lib/versions/library.so.1", []byte("library"), 0644)
+	assert.Nil(t, memFS.Symlink("versions/library.so.1", "/lib/library.so"))
+	assert.Nil(t, memFS.Symlink("/lib/versions", "/latest"))
+
+	content, readErr := memFS.ReadFile("/lib/library.so")
+	assert.Nil(t, readErr)
+	assert.Equal(t, "library", string(content))
+	content, readErr = memFS.ReadFile("/latest/library.so.1")
+	assert.Nil(t, readErr)
+	assert.Equal(t, "library", string(content))
+
+	info, lstatErr := memFS.Lstat("/lib/library.so")
+	assert.Nil(t, lstatErr)
+	assert.True(t, info.Mode()&os.ModeSymlink != 0)
+
+	// symbolic links can't replace an existing file, and loops are detected
+	assert.True(t, os.IsExist(memFS.Symlink("elsewhere", "/lib/library.so")))
+	memFS.Symlink("/loop", "/loop")
+	_, statErr := memFS.Stat("/loop")
+	assert.NotNil(t, statErr)
+}
+
+func TestMemFS_Link_ShouldShareContent(t *testing.T) {
+	t.Parallel()
+	memFS := NewMemFS()
+
+	memFS.WriteFile("/file", []byte("original"), 0644)
+	assert.Nil(t, memFS.Link("/file", "/link"))
+	memFS.WriteFile("/file", []byte("changed"), 0644)
+
+	content, _ := memFS.ReadFile("/link")
+	assert.Equal(t, "changed", string(content))
+
+	// the content stays reachable through the other link
+	assert.Nil(t, memFS.Remove("/file"))
+	content, _ = memFS.ReadFile("/link")
+	assert.Equal(t, "changed", string(content))
+
+	memFS.MkdirAll("/dir", 0755)
+	assert.NotNil(t, memFS.Link("/dir", "/dir-link"))
+}
+
+func TestMemFS_RenameAndRemove_ShouldMoveDirectoryTrees(t *testing.T) {
+	t.Parallel()
+	memFS := NewMemFS()
+
+	memFS.MkdirAll("/staging/item/lib", 0755)
+	memFS.WriteFile("/staging/item/lib/library.so", []byte("library"), 0644)
+
+	assert.Nil(t, memFS.Rename("/staging/item", "/item"))
+	content, _ := memFS.ReadFile("/item/lib/library.so")
+	assert.Equal(t, "library", string(content))
+	_, statErr := memFS.Stat("/staging/item")
+	assert.True(t, os.IsNotExist(statErr))
+
+	// non empty directories are only removed by RemoveAll
+	assert.NotNil(t, memFS.Remove("/item"))
+	assert.Nil(t, memFS.RemoveAll("/item"))
+	_, statErr = memFS.Stat("/item/lib/library.so")
+	assert.True(t, os.IsNotExist(statErr))
+	assert.Nil(t, memFS.Remove("/staging"))
+}
+
+func TestMemFS_ReadDir_ShouldListChildrenByName(t *testing.T) {
+	t.Parallel()
+	memFS := NewMemFS()
+
+	memFS.MkdirAll("/dir/b/nested", 0755)
+	memFS.WriteFile("/dir/c", nil, 0644)
+	memFS.WriteFile("/dir/a", []byte("a"), 0644)
+
+	infos, readErr := memFS.ReadDir("/dir")
+	assert.Nil(t, readErr)
+	assert.Equal(t, 3, len(infos))
+	assert.Equal(t, "a", infos[0].Name())
+	assert.Equal(t, "b", infos[1].Name())
+	assert.True(t, infos[1].IsDir())
+	assert.Equal(t, "c", infos[2].Name())
+
+	_, readErr = memFS.ReadDir("/dir/a")
+	assert.NotNil(t, readErr)
+}
+
+func TestMemFS_ChmodAndChtimes_ShouldUpdateFileInfo(t *testing.T) {
+	t.Parallel()
+	memFS := NewMemFS()
+	modTime := time.Unix(1500000000, 0)
+
+	memFS.WriteFile("/script.sh", []byte("echo"), 0644)
+	assert.Nil(t, memFS.Chmod("/script.sh", 0755))
+	assert.Nil(t, memFS.Chtimes("/script.sh", modTime, modTime))
+
+	info, _ := memFS.Stat("/script.sh")
+	assert.Equal(t, os.FileMode(0755), info.Mode())
+	assert.True(t, modTime.Equal(info.ModTime()))
+}
+
+func TestMemFS_LchownAndSetxattr_ShouldBeInSys(t *testing.T) {
+	t.Parallel()
+	memFS := NewMemFS()
+
+	memFS.WriteFile("/node", []byte("node"), 0755)
+	memFS.Symlink("node", "/node.1")
+	assert.Nil(t, memFS.Lchown("/node.1", 1000, 1000))
+	assert.Nil(t, memFS.Setxattr("/node.1", "security.capability", []byte("caps")))
+
+	// Lchown changes the link itself, Setxattr follows it
+	info, _ := memFS.Lstat("/node.1")
+	assert.Equal(t, 1000, info.Sys().(*MemSys).Uid)
+	info, _ = memFS.Stat("/node")
+	assert.Equal(t, 0, info.Sys().(*MemSys).Uid)
+	assert.Equal(t, map[string][]byte{"security.capability": []byte("caps")}, info.Sys().(*MemSys).Xattrs)
+
+	assert.True(t, os.IsNotExist(memFS.Lchown("/missing", 0, 0)))
+}
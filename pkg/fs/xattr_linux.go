@@ -0,0 +1,10 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import "syscall"
+
+func setxattr(name string, attr string, data []byte) error {
+	return syscall.Setxattr(name, attr, data, 0)
+}
@@ -0,0 +1,32 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package fs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestLocalFS_Setxattr_ShouldSetExtendedAttribute(t *testing.T) {
+	t.Parallel()
+	tempDir, _ := ioutil.TempDir("", "xattr")
+	defer os.RemoveAll(tempDir)
+	fileName := filepath.Join(tempDir, "file")
+	ioutil.WriteFile(fileName, []byte("content"), 0644)
+
+	setErr := NewLocalFS().Setxattr(fileName, "user.bundle", []byte("value"))
+	if pathErr, ok := setErr.(*os.PathError); ok && pathErr.Err == syscall.ENOTSUP {
+		t.Skip("extended attributes are not supported by the temporary directory")
+	}
+	assert.Nil(t, setErr)
+
+	value := make([]byte, 16)
+	n, getErr := syscall.Getxattr(fileName, "user.bundle", value)
+	assert.Nil(t, getErr)
+	assert.Equal(t, "value", string(value[:n]))
+}
@@ -0,0 +1,14 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !linux
+// +build !linux
+
+package fs
+
+import "errors"
+
+// extended attributes are only set on linux, where they hold file capabilities
+func setxattr(name string, attr string, data []byte) error {
+	return errors.New("extended attributes are not supported on this platform")
+}
@@ -0,0 +1,48 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package logging provides the structured logger used across the library.
+//
+// The library is silent by default, a *slog.Logger can be set on the Provider,
+// the stores and the streamers to get leveled records with fields such as the
+// overlay, its sha256, the bytes involved or the attempt of a retry.
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Attribute keys shared by the records of the library
+const (
+	KeyOverlay = "overlay"
+	KeySha256  = "sha256"
+	KeyBytes   = "bytes"
+	KeyAttempt = "attempt"
+	KeyItem    = "item"
+	KeyURL     = "url"
+	KeyError   = "error"
+)
+
+var discard = slog.New(discardHandler{})
+
+// Discard returns a logger which drops every record, it is the default logger of the library
+func Discard() *slog.Logger {
+	return discard
+}
+
+// OrDiscard returns logger, or a logger which drops every record when it is nil
+func OrDiscard(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return discard
+	}
+	return logger
+}
+
+// discardHandler is a slog.Handler which is never enabled
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
@@ -0,0 +1,31 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"testing"
+)
+
+func TestOrDiscard_WithNilLogger_ShouldDiscardRecords(t *testing.T) {
+	t.Parallel()
+	logger := OrDiscard(nil)
+
+	assert.Equal(t, Discard(), logger)
+	assert.False(t, logger.Enabled(context.Background(), slog.LevelError))
+	logger.With(KeyItem, "item").WithGroup("group").Error("dropped")
+}
+
+func TestOrDiscard_WithLogger_ShouldReturnIt(t *testing.T) {
+	t.Parallel()
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	OrDiscard(logger).Info("kept", KeyBytes, 10)
+
+	assert.Contains(t, logs.String(), "msg=kept bytes=10")
+}
@@ -0,0 +1,107 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package metrics provides the hook the library reports its metrics to.
+//
+// No metrics are recorded by default, a Recorder can be set on the Provider, the stores
+// and the S3 streamer to count the bundles fetched, the cache hits and misses, the
+// extraction durations, the evictions and the S3 retries. The recorder of the
+// prometheus subpackage registers them with the Prometheus client.
+package metrics
+
+// DefaultBuckets are the upper bounds in seconds of the histograms of the durations,
+// they range from a cached item to the download of a large bundle
+var DefaultBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 120, 300, 600}
+
+// Names of the metrics recorded by the library
+const (
+	// counter of the bundles fetched by the Provider, by LabelErrorType
+	BundleRequestsTotal = "bundle_requests_total"
+	// histogram of the time taken to fetch and extract a bundle, by LabelErrorType
+	BundleRequestDurationSeconds = "bundle_request_duration_seconds"
+
+	// counter of the items put into a store, by LabelResult
+	StorePutsTotal = "store_puts_total"
+	// histogram of the time taken to extract the items which weren't in the store
+	StoreExtractionDurationSeconds = "store_extraction_duration_seconds"
+	// counter of the items removed from a store, by LabelReason
+	StoreEvictionsTotal = "store_evictions_total"
+	// gauge of the size of the items in a store, only set by stores with an index or a maximum size
+	StoreSizeBytes = "store_size_bytes"
+
+	// counter of the reads of S3 objects which were retried
+	S3ReadRetriesTotal = "s3_read_retries_total"
+	// counter of the bytes read from S3 objects
+	S3ReadBytesTotal = "s3_read_bytes_total"
+)
+
+// Labels of the metrics, and their values
+const (
+	// the ErrorType of the bundle error, ErrorTypeNone when the bundle was fetched
+	LabelErrorType = "error_type"
+	ErrorTypeNone  = "NONE"
+
+	// whether the item was already in the store
+	LabelResult = "result"
+	ResultHit   = "hit"
+	ResultMiss  = "miss"
+
+	// why the item was removed
+	LabelReason   = "reason"
+	ReasonCleanup = "cleanup"
+	ReasonMaxSize = "max_size"
+)
+
+// help describes the metrics of the library
+var help = map[string]string{
+	BundleRequestsTotal:            "Bundles fetched by the provider, by error type.",
+	BundleRequestDurationSeconds:   "Time taken to fetch and extract a bundle, by error type.",
+	StorePutsTotal:                 "Items put into the store, by whether they were already in it.",
+	StoreExtractionDurationSeconds: "Time taken to extract the items which were not in the store.",
+	StoreEvictionsTotal:            "Items removed from the store, by reason.",
+	StoreSizeBytes:                 "Size of the items in the store.",
+	S3ReadRetriesTotal:             "Reads of S3 objects which were retried.",
+	S3ReadBytesTotal:               "Bytes read from S3 objects.",
+}
+
+// Help describes the metric name of the library, it is empty for other metrics
+func Help(name string) string {
+	return help[name]
+}
+
+// Labels are the label names and values of a metric, nil when it has none
+type Labels map[string]string
+
+// Recorder receives the metrics of the library. Its methods are called concurrently,
+// and while bundles are read, so they shouldn't block.
+type Recorder interface {
+	// AddCounter adds value to the counter name
+	AddCounter(name string, value float64, labels Labels)
+
+	// ObserveHistogram adds value to the histogram name
+	ObserveHistogram(name string, value float64, labels Labels)
+
+	// SetGauge sets the gauge name to value
+	SetGauge(name string, value float64, labels Labels)
+}
+
+var discard Recorder = discardRecorder{}
+
+// Discard returns a recorder which drops every metric, it is the default recorder of the library
+func Discard() Recorder {
+	return discard
+}
+
+// OrDiscard returns recorder, or a recorder which drops every metric when it is nil
+func OrDiscard(recorder Recorder) Recorder {
+	if recorder == nil {
+		return discard
+	}
+	return recorder
+}
+
+type discardRecorder struct{}
+
+func (discardRecorder) AddCounter(string, float64, Labels)       {}
+func (discardRecorder) ObserveHistogram(string, float64, Labels) {}
+func (discardRecorder) SetGauge(string, float64, Labels)         {}
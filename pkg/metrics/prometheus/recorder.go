@@ -0,0 +1,117 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package prometheus provides a metrics.Recorder which registers the metrics of the library
+// with the Prometheus client, for applications already serving their own Prometheus metrics.
+package prometheus
+
+import (
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// the labels of the metrics of the library, Prometheus needs them before the metrics are recorded
+var (
+	counterLabels = map[string][]string{
+		metrics.BundleRequestsTotal: {metrics.LabelErrorType},
+		metrics.StorePutsTotal:      {metrics.LabelResult},
+		metrics.StoreEvictionsTotal: {metrics.LabelReason},
+		metrics.S3ReadRetriesTotal:  nil,
+		metrics.S3ReadBytesTotal:    nil,
+	}
+	histogramLabels = map[string][]string{
+		metrics.BundleRequestDurationSeconds:   {metrics.LabelErrorType},
+		metrics.StoreExtractionDurationSeconds: nil,
+	}
+	gaugeLabels = map[string][]string{
+		metrics.StoreSizeBytes: nil,
+	}
+)
+
+// Recorder is a metrics.Recorder keeping the metrics of the library in Prometheus vectors.
+// It is a prometheus.Collector, to be registered with the registry scraped by Prometheus:
+//
+//	recorder := bundleprometheus.NewRecorder()
+//	prometheus.MustRegister(recorder)
+//	provider.SetMetrics(recorder)
+//
+// Metrics other than the ones of the library, or with other labels, are ignored.
+type Recorder struct {
+	counters   map[string]*prometheus.CounterVec
+	histograms map[string]*prometheus.HistogramVec
+	gauges     map[string]*prometheus.GaugeVec
+}
+
+// NewRecorder creates a Recorder whose histograms have buckets as upper bounds, metrics.DefaultBuckets if none are given
+func NewRecorder(buckets ...float64) *Recorder {
+	if len(buckets) == 0 {
+		buckets = metrics.DefaultBuckets
+	}
+
+	r := &Recorder{
+		counters:   make(map[string]*prometheus.CounterVec),
+		histograms: make(map[string]*prometheus.HistogramVec),
+		gauges:     make(map[string]*prometheus.GaugeVec),
+	}
+	for name, labels := range counterLabels {
+		r.counters[name] = prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: metrics.Help(name)}, labels)
+	}
+	for name, labels := range histogramLabels {
+		r.histograms[name] = prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: metrics.Help(name), Buckets: buckets}, labels)
+	}
+	for name, labels := range gaugeLabels {
+		r.gauges[name] = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: metrics.Help(name)}, labels)
+	}
+	return r
+}
+
+func (r *Recorder) AddCounter(name string, value float64, labels metrics.Labels) {
+	// counters can only go up, Add panics otherwise
+	if vec, ok := r.counters[name]; ok && value >= 0 {
+		if counter, err := vec.GetMetricWith(prometheus.Labels(labels)); err == nil {
+			counter.Add(value)
+		}
+	}
+}
+
+func (r *Recorder) ObserveHistogram(name string, value float64, labels metrics.Labels) {
+	if vec, ok := r.histograms[name]; ok {
+		if histogram, err := vec.GetMetricWith(prometheus.Labels(labels)); err == nil {
+			histogram.Observe(value)
+		}
+	}
+}
+
+func (r *Recorder) SetGauge(name string, value float64, labels metrics.Labels) {
+	if vec, ok := r.gauges[name]; ok {
+		if gauge, err := vec.GetMetricWith(prometheus.Labels(labels)); err == nil {
+			gauge.Set(value)
+		}
+	}
+}
+
+// Describe sends the descriptions of the metrics of the library, see prometheus.Collector
+func (r *Recorder) Describe(ch chan<- *prometheus.Desc) {
+	for _, vec := range r.counters {
+		vec.Describe(ch)
+	}
+	for _, vec := range r.histograms {
+		vec.Describe(ch)
+	}
+	for _, vec := range r.gauges {
+		vec.Describe(ch)
+	}
+}
+
+// Collect sends the metrics recorded so far, see prometheus.Collector
+func (r *Recorder) Collect(ch chan<- prometheus.Metric) {
+	for _, vec := range r.counters {
+		vec.Collect(ch)
+	}
+	for _, vec := range r.histograms {
+		vec.Collect(ch)
+	}
+	for _, vec := range r.gauges {
+		vec.Collect(ch)
+	}
+}
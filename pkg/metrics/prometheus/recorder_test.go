@@ -0,0 +1,53 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheus
+
+import (
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestRecorder_Register_ShouldGatherMetricsOfLibrary(t *testing.T) {
+	t.Parallel()
+	registry := prometheus.NewRegistry()
+	recorder := NewRecorder(1, 10)
+	assert.Nil(t, registry.Register(recorder))
+
+	recorder.AddCounter(metrics.StorePutsTotal, 1, metrics.Labels{metrics.LabelResult: metrics.ResultHit})
+	recorder.AddCounter(metrics.StorePutsTotal, 2, metrics.Labels{metrics.LabelResult: metrics.ResultHit})
+	recorder.ObserveHistogram(metrics.BundleRequestDurationSeconds, 5, metrics.Labels{metrics.LabelErrorType: metrics.ErrorTypeNone})
+	recorder.SetGauge(metrics.StoreSizeBytes, 20, nil)
+
+	assert.Nil(t, testutil.GatherAndCompare(registry, strings.NewReader(`# HELP bundle_request_duration_seconds Time taken to fetch and extract a bundle, by error type.
+# TYPE bundle_request_duration_seconds histogram
+bundle_request_duration_seconds_bucket{error_type="NONE",le="1"} 0
+bundle_request_duration_seconds_bucket{error_type="NONE",le="10"} 1
+bundle_request_duration_seconds_bucket{error_type="NONE",le="+Inf"} 1
+bundle_request_duration_seconds_sum{error_type="NONE"} 5
+bundle_request_duration_seconds_count{error_type="NONE"} 1
+# HELP store_puts_total Items put into the store, by whether they were already in it.
+# TYPE store_puts_total counter
+store_puts_total{result="hit"} 3
+# HELP store_size_bytes Size of the items in the store.
+# TYPE store_size_bytes gauge
+store_size_bytes 20
+`), metrics.BundleRequestDurationSeconds, metrics.StorePutsTotal, metrics.StoreSizeBytes))
+}
+
+func TestRecorder_WithUnknownMetricOrLabels_ShouldIgnoreIt(t *testing.T) {
+	t.Parallel()
+	recorder := NewRecorder()
+
+	recorder.AddCounter("custom_total", 1, nil)
+	recorder.AddCounter(metrics.StorePutsTotal, 1, metrics.Labels{"custom": "label"})
+	recorder.AddCounter(metrics.S3ReadBytesTotal, -1, nil)
+	recorder.ObserveHistogram(metrics.StoreSizeBytes, 1, nil)
+	recorder.SetGauge(metrics.StorePutsTotal, 1, nil)
+
+	assert.Equal(t, 0, testutil.CollectAndCount(recorder, metrics.StorePutsTotal, metrics.StoreSizeBytes, metrics.S3ReadBytesTotal))
+}
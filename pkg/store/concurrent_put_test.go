@@ -0,0 +1,129 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"errors"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// gatedExtractor signals every time it starts extracting, and extracts once released
+type gatedExtractor struct {
+	started     chan struct{}
+	release     chan struct{}
+	extractions int32
+	err         error
+}
+
+func newGatedExtractor(err error) *gatedExtractor {
+	return &gatedExtractor{started: make(chan struct{}, 1), release: make(chan struct{}), err: err}
+}
+
+func (e *gatedExtractor) Extract(extractLocation string, fileSystem fs.FileSystem) error {
+	atomic.AddInt32(&e.extractions, 1)
+	e.started <- struct{}{}
+	<-e.release
+	if e.err != nil {
+		return e.err
+	}
+	return (&fileExtractor{}).Extract(extractLocation, fileSystem)
+}
+
+type putResult struct {
+	itemPath string
+	err      error
+}
+
+// puts key into bundleStore in the background, the result is sent once the Put returns
+func putInBackground(bundleStore *simpleStore, key string, extractor *gatedExtractor) chan putResult {
+	result := make(chan putResult, 1)
+	go func() {
+		itemPath, putErr := bundleStore.Put(key, extractor)
+		result <- putResult{itemPath, putErr}
+	}()
+	return result
+}
+
+func waitFor(t *testing.T, signal chan struct{}) {
+	select {
+	case <-signal:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+	}
+}
+
+func TestSimpleStore_Put_ConcurrentlyWithSameKey_ShouldExtractOnce(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	bundleStore := NewSimpleStore(rootPath).(*simpleStore)
+	extractor := newGatedExtractor(nil)
+	firstResult := putInBackground(bundleStore, sha256First, extractor)
+	waitFor(t, extractor.started)
+	secondResult := putInBackground(bundleStore, sha256First, extractor)
+
+	// the second Put waits for the extraction of the first one
+	select {
+	case <-secondResult:
+		t.Fatal("second Put returned before the extraction finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(extractor.release)
+
+	first, second := <-firstResult, <-secondResult
+	assert.Nil(t, first.err)
+	assert.Nil(t, second.err)
+	assert.Equal(t, first.itemPath, second.itemPath)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&extractor.extractions))
+	assert.Equal(t, 2, bundleStore.storeItems[sha256First].refCount)
+}
+
+func TestSimpleStore_Put_ConcurrentlyWithDifferentKeys_ShouldExtractAtTheSameTime(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	bundleStore := NewSimpleStore(rootPath).(*simpleStore)
+	firstExtractor := newGatedExtractor(nil)
+	secondExtractor := newGatedExtractor(nil)
+	firstResult := putInBackground(bundleStore, sha256First, firstExtractor)
+	secondResult := putInBackground(bundleStore, sha256Second, secondExtractor)
+
+	// both extractions are in progress before either of them is released
+	waitFor(t, firstExtractor.started)
+	waitFor(t, secondExtractor.started)
+	close(firstExtractor.release)
+	close(secondExtractor.release)
+
+	assert.Nil(t, (<-firstResult).err)
+	assert.Nil(t, (<-secondResult).err)
+	assert.True(t, bundleStore.Exists(sha256First))
+	assert.True(t, bundleStore.Exists(sha256Second))
+}
+
+func TestSimpleStore_Put_ConcurrentlyWithSameKey_WhenExtractionFails_ShouldShareError(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	bundleStore := NewSimpleStore(rootPath).(*simpleStore)
+	extractErr := errors.New("extract failed")
+	extractor := newGatedExtractor(extractErr)
+	firstResult := putInBackground(bundleStore, sha256First, extractor)
+	waitFor(t, extractor.started)
+	secondResult := putInBackground(bundleStore, sha256First, extractor)
+	time.Sleep(50 * time.Millisecond)
+	close(extractor.release)
+
+	assert.Equal(t, extractErr, (<-firstResult).err)
+	assert.Equal(t, extractErr, (<-secondResult).err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&extractor.extractions))
+	assert.False(t, bundleStore.Exists(sha256First))
+}
@@ -0,0 +1,67 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"context"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// cancellingExtractor writes part of an item, then cancels the Put and waits for the context to be done
+type cancellingExtractor struct {
+	cancel context.CancelFunc
+}
+
+func (e *cancellingExtractor) Extract(extractLocation string, fileSystem fs.FileSystem) error {
+	return e.ExtractWithContext(context.Background(), extractLocation, fileSystem)
+}
+
+func (e *cancellingExtractor) ExtractWithContext(ctx context.Context, extractLocation string, fileSystem fs.FileSystem) error {
+	if err := (&fileExtractor{}).Extract(extractLocation, fileSystem); err != nil {
+		return err
+	}
+	e.cancel()
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestSimpleStore_PutWithContext_WhenCancelled_ShouldRemovePartialItem(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	bundleStore, _ := OpenSimpleStore(rootPath)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	putPath, putErr := bundleStore.(*simpleStore).PutWithContext(ctx, sha256First, &cancellingExtractor{cancel: cancel})
+
+	assert.Equal(t, "", putPath)
+	assert.Equal(t, context.Canceled, putErr)
+	assert.False(t, bundleStore.Exists(sha256First))
+	_, statErr := os.Stat(filepath.Join(rootPath, sha256First))
+	assert.True(t, os.IsNotExist(statErr))
+	stagingItems, _ := filepath.Glob(filepath.Join(rootPath, stagingDirectoryName, "*"))
+	assert.Empty(t, stagingItems)
+}
+
+func TestSimpleStore_PutWithContext_WhenAlreadyCancelled_ShouldNotExtract(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	bundleStore := NewSimpleStore(rootPath)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, putErr := bundleStore.(*simpleStore).PutWithContext(ctx, sha256First, &fileExtractor{})
+
+	assert.Equal(t, context.Canceled, putErr)
+	_, statErr := os.Stat(filepath.Join(rootPath, sha256First))
+	assert.True(t, os.IsNotExist(statErr))
+}
@@ -0,0 +1,119 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"bytes"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/bundle"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/stream"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/stream/local"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// bundles are streamed from, and extracted into, memory
+var memFS = fs.NewMemFS()
+
+func init() {
+	stream.RegisterStreamer(local.NewStreamerWithFileSystem(memFS))
+}
+
+// writes a v2 bundle made of the given overlay directories into memFS at bundlePath,
+// the files of the overlays are modified at modTime
+func writeTestBundle(t *testing.T, bundlePath string, overlays map[string]map[string]string, modTime time.Time) {
+	tempDir, _ := ioutil.TempDir("", "end-to-end")
+	defer os.RemoveAll(tempDir)
+
+	var overlayPaths []string
+	for overlayName, files := range overlays {
+		overlayPath := filepath.Join(tempDir, overlayName)
+		for name, content := range files {
+			os.MkdirAll(filepath.Dir(filepath.Join(overlayPath, name)), 0755)
+			ioutil.WriteFile(filepath.Join(overlayPath, name), []byte(content), 0755)
+			os.Chtimes(filepath.Join(overlayPath, name), modTime, modTime)
+		}
+		overlayPaths = append(overlayPaths, overlayPath)
+	}
+	sort.Strings(overlayPaths)
+
+	var bundleBytes bytes.Buffer
+	assert.Nil(t, bundle.NewWriter().Write(&bundleBytes, overlayPaths))
+	assert.Nil(t, memFS.MkdirAll(filepath.Dir(bundlePath), 0755))
+	assert.Nil(t, memFS.WriteFile(bundlePath, bundleBytes.Bytes(), 0644))
+}
+
+func TestEndToEnd_GetBundle_InMemory_ShouldExtractOverlays(t *testing.T) {
+	t.Parallel()
+	rootPath := "/end-to-end/cache"
+	writeTestBundle(t, "/end-to-end/bundles/bundle.tar", map[string]map[string]string{
+		"dependencies": {"setup.sh": "dependencies", "lib/library.so": "library"},
+		"workspace":    {"setup.sh": "workspace"},
+	}, time.Now())
+
+	bundleStore := NewSimpleStore(rootPath, WithFileSystem(memFS))
+	provider := bundle.NewProvider(bundleStore)
+	b, getErr := provider.GetBundle("/end-to-end/bundles/bundle.tar")
+	assert.Nil(t, getErr)
+
+	assert.Equal(t, 2, len(b.PosixSourceCommands()))
+	keys := bundleStore.GetInUseItemKeys()
+	assert.Equal(t, 2, len(keys))
+
+	// the overlays are in memory, with their contents and modes
+	itemPaths := map[string]string{}
+	for _, key := range keys {
+		itemPath := bundleStore.GetPath(key)
+		setup, readErr := memFS.ReadFile(filepath.Join(itemPath, "setup.sh"))
+		assert.Nil(t, readErr)
+		itemPaths[string(setup)] = itemPath
+
+		info, statErr := memFS.Stat(filepath.Join(itemPath, "setup.sh"))
+		assert.Nil(t, statErr)
+		assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	}
+	assert.Equal(t, 2, len(itemPaths))
+	library, readErr := memFS.ReadFile(filepath.Join(itemPaths["dependencies"], "lib", "library.so"))
+	assert.Nil(t, readErr)
+	assert.Equal(t, "library", string(library))
+	_, statErr := memFS.Stat(filepath.Join(itemPaths["workspace"], "lib"))
+	assert.True(t, os.IsNotExist(statErr))
+
+	// nothing was written to the local disk
+	_, statErr = os.Stat(rootPath)
+	assert.True(t, os.IsNotExist(statErr))
+
+	// releasing the bundle lets the store clean the overlays up
+	b.Release()
+	bundleStore.Cleanup()
+	for _, itemPath := range itemPaths {
+		_, statErr = memFS.Stat(itemPath)
+		assert.True(t, os.IsNotExist(statErr))
+	}
+}
+
+func TestEndToEnd_GetBundle_WithPreserveModTimes_ShouldKeepModTimes(t *testing.T) {
+	t.Parallel()
+	modTime := time.Unix(1500000000, 0)
+	writeTestBundle(t, "/preserve/bundles/bundle.tar", map[string]map[string]string{
+		"workspace": {"setup.sh": "preserved workspace"},
+	}, modTime)
+
+	bundleStore := NewSimpleStore("/preserve/cache", WithFileSystem(memFS))
+	provider := bundle.NewProvider(bundleStore)
+	provider.SetExtractOptions(bundle.ExtractOptions{PreserveModTimes: true})
+	_, getErr := provider.GetBundle("/preserve/bundles/bundle.tar")
+	assert.Nil(t, getErr)
+
+	keys := bundleStore.GetInUseItemKeys()
+	assert.Equal(t, 1, len(keys))
+	info, statErr := memFS.Stat(filepath.Join(bundleStore.GetPath(keys[0]), "setup.sh"))
+	assert.Nil(t, statErr)
+	assert.True(t, modTime.Equal(info.ModTime()))
+}
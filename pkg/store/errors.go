@@ -0,0 +1,28 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"fmt"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/bundle"
+)
+
+// MaxSizeExceededError is returned by Put when an item doesn't fit in
+// the maximum size of the store, even after evicting every unreferenced item.
+// It matches bundle.ErrOutOfSpace with errors.Is.
+type MaxSizeExceededError struct {
+	Key            string
+	Size           int64
+	ReferencedSize int64
+	MaxSize        int64
+}
+
+func (e *MaxSizeExceededError) Error() string {
+	return fmt.Sprintf("item %s of %d bytes does not fit in the store's maximum size of %d bytes, %d bytes are in use",
+		e.Key, e.Size, e.MaxSize, e.ReferencedSize)
+}
+
+func (e *MaxSizeExceededError) Is(target error) bool {
+	return target == bundle.ErrOutOfSpace
+}
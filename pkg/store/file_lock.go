@@ -0,0 +1,87 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// errLocked is returned when a lock is held by someone else and we asked not to wait for it
+var errLocked = errors.New("file is locked")
+
+// how often a lock is retried while waiting for it with a context
+const lockPollInterval = 100 * time.Millisecond
+
+// fileLock is an advisory lock on a file, used to coordinate processes sharing a store.
+// Exclusive locks on the same file conflict with any other lock, shared locks only
+// with exclusive ones. Locks conflict even within a process, as long as they are
+// taken through separate fileLocks.
+type fileLock struct {
+	file *os.File
+}
+
+// lockFile locks the file at path, creating it if needed, and waits until the lock is acquired
+func lockFile(path string) (*fileLock, error) {
+	return newFileLock(path, false, true)
+}
+
+// lockFileShared takes a shared lock on the file at path, creating it if needed, and waits until the lock is acquired
+func lockFileShared(path string) (*fileLock, error) {
+	return newFileLock(path, true, true)
+}
+
+// lockFileWithContext is the same as lockFile, but stops waiting and returns ctx.Err() once ctx is done
+func lockFileWithContext(ctx context.Context, path string) (*fileLock, error) {
+	// a blocking lock can't be interrupted, poll instead when ctx can be cancelled
+	if ctx.Done() == nil {
+		return lockFile(path)
+	}
+
+	for {
+		lock, lockErr := tryLockFile(path)
+		if lockErr != errLocked {
+			return lock, lockErr
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// tryLockFile locks the file at path, creating it if needed, or returns errLocked if it's already locked
+func tryLockFile(path string) (*fileLock, error) {
+	return newFileLock(path, false, false)
+}
+
+func newFileLock(path string, shared bool, wait bool) (*fileLock, error) {
+	if mkdirErr := os.MkdirAll(filepath.Dir(path), os.FileMode(storeDirectoryMode)); mkdirErr != nil {
+		return nil, mkdirErr
+	}
+
+	file, openErr := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if openErr != nil {
+		return nil, openErr
+	}
+
+	if lockErr := flock(file, shared, wait); lockErr != nil {
+		file.Close()
+		return nil, lockErr
+	}
+	return &fileLock{file: file}, nil
+}
+
+// Unlock releases the lock, closing the file releases it. It does nothing on a nil lock.
+func (l *fileLock) Unlock() error {
+	if l == nil {
+		return nil
+	}
+	return l.file.Close()
+}
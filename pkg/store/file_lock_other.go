@@ -0,0 +1,18 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package store
+
+import (
+	"os"
+)
+
+const fileLocksSupported = false
+
+// flock is not supported on this platform, stores are only coordinated within a process
+func flock(file *os.File, shared bool, wait bool) error {
+	return nil
+}
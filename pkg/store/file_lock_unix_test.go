@@ -0,0 +1,266 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package store
+
+import (
+	"context"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingExtractor extracts a single file once it's told to
+type blockingExtractor struct {
+	started     chan struct{}
+	release     chan struct{}
+	invocations int32
+}
+
+func newBlockingExtractor() *blockingExtractor {
+	return &blockingExtractor{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+}
+
+func (e *blockingExtractor) Extract(extractLocation string, fileSystem fs.FileSystem) error {
+	if atomic.AddInt32(&e.invocations, 1) == 1 {
+		close(e.started)
+	}
+	<-e.release
+	return (&fileExtractor{}).Extract(extractLocation, fileSystem)
+}
+
+func TestFileLock_TryLock_WhenLocked_ShouldReturnErrLocked(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	lockPath := filepath.Join(rootPath, storeLockFileName)
+	firstLock, firstErr := lockFile(lockPath)
+	assert.Nil(t, firstErr)
+
+	_, secondErr := tryLockFile(lockPath)
+	assert.Equal(t, errLocked, secondErr)
+
+	firstLock.Unlock()
+	thirdLock, thirdErr := tryLockFile(lockPath)
+	assert.Nil(t, thirdErr)
+	thirdLock.Unlock()
+}
+
+func TestSharedStore_Put_WhenOtherStoreExtractsSameKey_ShouldWaitAndReuse(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	// two stores on the same root path behave like two processes, as their file locks conflict
+	firstStore, _ := OpenSimpleStore(rootPath)
+	secondStore, _ := OpenSimpleStore(rootPath)
+
+	firstExtractor := newBlockingExtractor()
+	secondExtractor := newBlockingExtractor()
+	close(secondExtractor.release)
+
+	firstDone := make(chan error)
+	go func() {
+		_, err := firstStore.Put(sha256First, firstExtractor)
+		firstDone <- err
+	}()
+	<-firstExtractor.started
+
+	secondDone := make(chan error)
+	go func() {
+		_, err := secondStore.Put(sha256First, secondExtractor)
+		secondDone <- err
+	}()
+
+	select {
+	case <-secondDone:
+		t.Fatal("second store should wait for the extraction of the first store")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(firstExtractor.release)
+	assert.Nil(t, <-firstDone)
+	assert.Nil(t, <-secondDone)
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&secondExtractor.invocations))
+	index, _ := readIndex(fs.NewLocalFS(), filepath.Join(rootPath, indexFileName))
+	assert.Equal(t, 2, index.Items[sha256First].RefCount)
+}
+
+func TestSharedStore_Cleanup_ShouldKeepItemsInUseByOtherStore(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	firstStore, _ := OpenSimpleStore(rootPath)
+	secondStore, _ := OpenSimpleStore(rootPath)
+
+	firstStore.Put(sha256First, &fileExtractor{})
+	secondStore.Cleanup()
+
+	_, statErr := os.Stat(filepath.Join(rootPath, sha256First))
+	assert.Nil(t, statErr)
+
+	firstStore.Release(sha256First)
+	secondStore.Cleanup()
+
+	_, statErr = os.Stat(filepath.Join(rootPath, sha256First))
+	assert.True(t, os.IsNotExist(statErr))
+	// the first store sees the cleanup of the second one
+	assert.NotNil(t, firstStore.Release(sha256First))
+}
+
+func TestOpenSimpleStore_WhenOtherStoreExitedWithoutRelease_ShouldDropItsReferences(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	exitedStore, _ := OpenSimpleStore(rootPath)
+	exitedStore.Put(sha256First, &fileExtractor{})
+	runningStore, _ := OpenSimpleStore(rootPath)
+	runningStore.Put(sha256Second, &fileExtractor{})
+	// the kernel releases the locks of a process when it exits
+	for _, useLocks := range exitedStore.(*simpleStore).useLocks {
+		for _, useLock := range useLocks {
+			useLock.Unlock()
+		}
+	}
+
+	reopenedStore, reopenErr := OpenSimpleStore(rootPath)
+
+	assert.Nil(t, reopenErr)
+	assert.Equal(t, []string{sha256Second}, reopenedStore.GetInUseItemKeys())
+	index, _ := readIndex(fs.NewLocalFS(), filepath.Join(rootPath, indexFileName))
+	assert.Equal(t, 0, index.Items[sha256First].RefCount)
+	assert.Equal(t, 1, index.Items[sha256Second].RefCount)
+	reopenedStore.Cleanup()
+	assert.False(t, reopenedStore.Exists(sha256First))
+	assert.True(t, reopenedStore.Exists(sha256Second))
+}
+
+func TestNewSimpleStore_WithoutSharedLocking_ShouldNotCreateLocks(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	bundleStore := NewSimpleStore(rootPath)
+	bundleStore.Put(sha256First, &fileExtractor{})
+	bundleStore.Release(sha256First)
+	bundleStore.Cleanup()
+
+	_, statErr := os.Stat(filepath.Join(rootPath, storeLockFileName))
+	assert.True(t, os.IsNotExist(statErr))
+	_, statErr = os.Stat(filepath.Join(rootPath, locksDirectoryName))
+	assert.True(t, os.IsNotExist(statErr))
+}
+
+func TestSharedStore_WithoutIndex_Cleanup_ShouldKeepItemsInUseByOtherStore(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	// reference counts are only in memory, the second store knows the item with a refCount of 0
+	firstStore := NewSimpleStore(rootPath, WithSharedLocking())
+	secondStore := NewSimpleStore(rootPath, WithSharedLocking())
+	firstStore.Put(sha256First, &fileExtractor{})
+	assert.Nil(t, secondStore.Load([]string{sha256First}))
+
+	secondStore.Cleanup()
+
+	_, statErr := os.Stat(filepath.Join(rootPath, sha256First))
+	assert.Nil(t, statErr)
+
+	firstStore.Release(sha256First)
+	secondStore.Cleanup()
+
+	_, statErr = os.Stat(filepath.Join(rootPath, sha256First))
+	assert.True(t, os.IsNotExist(statErr))
+}
+
+func TestSharedStore_WithoutIndex_Put_WhenOtherStoreRemovedItem_ShouldExtractItAgain(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	firstStore := NewSimpleStore(rootPath, WithSharedLocking())
+	secondStore := NewSimpleStore(rootPath, WithSharedLocking())
+	firstStore.Put(sha256First, &fileExtractor{})
+	firstStore.Release(sha256First)
+	secondStore.Load([]string{sha256First})
+	secondStore.Cleanup()
+
+	itemPath, putErr := firstStore.Put(sha256First, &fileExtractor{})
+
+	assert.Nil(t, putErr)
+	_, statErr := os.Stat(filepath.Join(itemPath, "setup.sh"))
+	assert.Nil(t, statErr)
+}
+
+func TestSharedStore_WithMaxSize_Put_ShouldNotEvictItemsInUseByOtherStore(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	firstStore := NewSimpleStore(rootPath, WithSharedLocking())
+	secondStore := NewSimpleStore(rootPath, WithSharedLocking(), WithMaxSize(twoItemsSize))
+	firstStore.Put(sha256First, &fileExtractor{})
+	secondStore.Load([]string{sha256First})
+	secondStore.Put(sha256Second, &fileExtractor{})
+
+	_, putErr := secondStore.Put(sha256Third, &fileExtractor{})
+
+	_, isSizeErr := putErr.(*MaxSizeExceededError)
+	assert.True(t, isSizeErr)
+	_, statErr := os.Stat(filepath.Join(rootPath, sha256First))
+	assert.Nil(t, statErr)
+}
+
+func TestNewSimpleStore_WhenStagingDirectoryIsLocked_ShouldNotRemoveIt(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	stagingPath := filepath.Join(rootPath, stagingDirectoryName, sha256First+"-"+uuid.New().String())
+	os.MkdirAll(stagingPath, 0755)
+
+	store := &simpleStore{rootPath: rootPath}
+	itemLock, _ := lockFile(store.getLockPathForItem(sha256First))
+
+	NewSimpleStore(rootPath, WithSharedLocking())
+	_, statErr := os.Stat(stagingPath)
+	assert.Nil(t, statErr)
+
+	itemLock.Unlock()
+
+	NewSimpleStore(rootPath, WithSharedLocking())
+	_, statErr = os.Stat(stagingPath)
+	assert.True(t, os.IsNotExist(statErr))
+}
+
+func TestFileLock_LockWithContext_WhenCancelled_ShouldStopWaiting(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	lockPath := filepath.Join(rootPath, storeLockFileName)
+	firstLock, _ := lockFile(lockPath)
+	defer firstLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*lockPollInterval)
+	defer cancel()
+	_, lockErr := lockFileWithContext(ctx, lockPath)
+
+	assert.Equal(t, context.DeadlineExceeded, lockErr)
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	indexFileName             = ".index.json"
+	indexFileMode fs.FileMode = 0644
+)
+
+// storeIndex is the on-disk record of the items in a store, it allows
+// reference counts and item metadata to survive process restarts.
+type storeIndex struct {
+	Items map[string]indexEntry `json:"items"`
+}
+
+type indexEntry struct {
+	RefCount       int       `json:"refCount"`
+	Size           int64     `json:"size"`
+	CreatedAt      time.Time `json:"createdAt"`
+	LastAccessedAt time.Time `json:"lastAccessedAt"`
+	Source         string    `json:"source,omitempty"`
+}
+
+// readIndex returns an empty index if there is no index file yet
+func readIndex(fileSystem fs.FileSystem, indexPath string) (*storeIndex, error) {
+	index := &storeIndex{Items: make(map[string]indexEntry)}
+
+	data, readErr := fileSystem.ReadFile(indexPath)
+	if os.IsNotExist(readErr) {
+		return index, nil
+	} else if readErr != nil {
+		return nil, readErr
+	}
+
+	if jsonErr := json.Unmarshal(data, index); jsonErr != nil {
+		return nil, fmt.Errorf("unable to parse store index %s: %v", indexPath, jsonErr)
+	}
+	if index.Items == nil {
+		index.Items = make(map[string]indexEntry)
+	}
+	return index, nil
+}
+
+// writeIndex replaces the index file atomically, readers either see the old or the new index
+func writeIndex(fileSystem fs.FileSystem, indexPath string, index *storeIndex) error {
+	data, jsonErr := json.MarshalIndent(index, "", "  ")
+	if jsonErr != nil {
+		return jsonErr
+	}
+
+	tempPath := indexPath + ".tmp"
+	if writeErr := fileSystem.WriteFile(tempPath, data, indexFileMode); writeErr != nil {
+		return writeErr
+	}
+	return fileSystem.Rename(tempPath, indexPath)
+}
+
+// dirSize returns the total size of the regular files under path
+func dirSize(fileSystem fs.FileSystem, path string) (int64, error) {
+	infos, readErr := fileSystem.ReadDir(path)
+	if readErr != nil {
+		return 0, readErr
+	}
+
+	var size int64
+	for _, info := range infos {
+		if info.IsDir() {
+			childSize, childErr := dirSize(fileSystem, filepath.Join(path, info.Name()))
+			if childErr != nil {
+				return 0, childErr
+			}
+			size += childSize
+		} else if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+	}
+	return size, nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	testSource      = "s3://bucket/bundle.tar"
+	testFileContent = "source me"
+)
+
+// fileExtractor extracts a single file, it is a bundle.SourcedExtractor
+type fileExtractor struct {
+	source string
+}
+
+func (e *fileExtractor) Extract(extractLocation string, fileSystem fs.FileSystem) error {
+	if err := fileSystem.MkdirAll(extractLocation, storeDirectoryMode); err != nil {
+		return err
+	}
+	return fileSystem.WriteFile(filepath.Join(extractLocation, "setup.sh"), []byte(testFileContent), 0644)
+}
+
+func (e *fileExtractor) Source() string {
+	return e.source
+}
+
+func createTempStoreRoot(t *testing.T) string {
+	rootPath, err := ioutil.TempDir("", "store")
+	assert.Nil(t, err)
+	return rootPath
+}
+
+func TestIndex_WriteAndRead_ShouldRoundTrip(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	indexPath := filepath.Join(rootPath, indexFileName)
+	index := &storeIndex{Items: map[string]indexEntry{
+		sha256First: {RefCount: 2, Size: 10, Source: testSource},
+	}}
+
+	assert.Nil(t, writeIndex(fs.NewLocalFS(), indexPath, index))
+	readBack, readErr := readIndex(fs.NewLocalFS(), indexPath)
+
+	assert.Nil(t, readErr)
+	assert.Equal(t, index.Items, readBack.Items)
+
+	// the temporary file is renamed into place
+	_, statErr := os.Stat(indexPath + ".tmp")
+	assert.True(t, os.IsNotExist(statErr))
+}
+
+func TestIndex_Read_WhenNoIndex_ShouldReturnEmptyIndex(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	index, readErr := readIndex(fs.NewLocalFS(), filepath.Join(rootPath, indexFileName))
+
+	assert.Nil(t, readErr)
+	assert.Empty(t, index.Items)
+}
+
+func TestIndex_Read_WhenCorrupted_ShouldReturnError(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	indexPath := filepath.Join(rootPath, indexFileName)
+	ioutil.WriteFile(indexPath, []byte("{"), 0644)
+
+	_, readErr := readIndex(fs.NewLocalFS(), indexPath)
+
+	assert.NotNil(t, readErr)
+}
+
+func TestOpenSimpleStore_WhenReopened_ShouldKeepRefCountsAndMetadata(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	firstStore, openErr := OpenSimpleStore(rootPath)
+	assert.Nil(t, openErr)
+	_, putErr := firstStore.Put(sha256First, &fileExtractor{source: testSource})
+	assert.Nil(t, putErr)
+	_, putErr = firstStore.Put(sha256Second, &fileExtractor{source: testSource})
+	assert.Nil(t, putErr)
+	assert.Nil(t, firstStore.Release(sha256Second))
+
+	secondStore, reopenErr := OpenSimpleStore(rootPath)
+	assert.Nil(t, reopenErr)
+
+	item := secondStore.(*simpleStore).storeItems[sha256First]
+	assert.Equal(t, 1, item.refCount)
+	assert.Equal(t, int64(len(testFileContent)), item.size)
+	assert.Equal(t, testSource, item.source)
+	assert.False(t, item.createdAt.IsZero())
+	assert.False(t, item.lastAccessedAt.IsZero())
+
+	// only the released item is cleaned up
+	secondStore.Cleanup()
+	assert.True(t, secondStore.Exists(sha256First))
+	assert.False(t, secondStore.Exists(sha256Second))
+	_, statErr := os.Stat(filepath.Join(rootPath, sha256First))
+	assert.Nil(t, statErr)
+}
+
+func TestOpenSimpleStore_WhenItemRemovedFromDisk_ShouldDropItem(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	firstStore, _ := OpenSimpleStore(rootPath)
+	firstStore.Put(sha256First, &fileExtractor{})
+	os.RemoveAll(filepath.Join(rootPath, sha256First))
+
+	secondStore, reopenErr := OpenSimpleStore(rootPath)
+
+	assert.Nil(t, reopenErr)
+	assert.False(t, secondStore.Exists(sha256First))
+	index, _ := readIndex(fs.NewLocalFS(), filepath.Join(rootPath, indexFileName))
+	assert.Empty(t, index.Items)
+}
+
+func TestOpenSimpleStore_Load_ShouldIndexItemsNotInIndex(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	(&fileExtractor{}).Extract(filepath.Join(rootPath, sha256First), fs.NewLocalFS())
+
+	bundleStore, _ := OpenSimpleStore(rootPath)
+	assert.Nil(t, bundleStore.Load([]string{sha256First}))
+
+	index, _ := readIndex(fs.NewLocalFS(), filepath.Join(rootPath, indexFileName))
+	assert.Equal(t, 0, index.Items[sha256First].RefCount)
+	assert.Equal(t, int64(len(testFileContent)), index.Items[sha256First].Size)
+}
+
+func TestOpenSimpleStore_Cleanup_WhenIndexCannotBeWritten_ShouldKeepItems(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	bundleStore, _ := OpenSimpleStore(rootPath)
+	bundleStore.Put(sha256First, &fileExtractor{})
+	bundleStore.Release(sha256First)
+
+	// the index is written to a temporary file first, a directory in its way makes the write fail
+	os.MkdirAll(filepath.Join(rootPath, indexFileName+".tmp"), 0755)
+	bundleStore.Cleanup()
+
+	assert.True(t, bundleStore.Exists(sha256First))
+	_, statErr := os.Stat(filepath.Join(rootPath, sha256First))
+	assert.Nil(t, statErr)
+	index, _ := readIndex(fs.NewLocalFS(), filepath.Join(rootPath, indexFileName))
+	assert.Contains(t, index.Items, sha256First)
+}
@@ -0,0 +1,61 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/metrics"
+	"log/slog"
+)
+
+// Option configures a store created by NewSimpleStore or OpenSimpleStore
+type Option func(*simpleStore)
+
+// WithMaxSize bounds the total size of the items in the store to maxSizeInBytes.
+// When an item doesn't fit, unreferenced items are evicted, least recently used first.
+// If the items in use alone leave no room for it, Put fails with a *MaxSizeExceededError.
+//
+// The size of an item is only known once it is extracted, so the store can
+// temporarily exceed its maximum size by the size of the items being put.
+func WithMaxSize(maxSizeInBytes int64) Option {
+	return func(s *simpleStore) {
+		s.maxSize = maxSizeInBytes
+	}
+}
+
+// WithFileSystem makes the store keep its items on fileSystem instead of the local disk,
+// for example an in-memory fs.FileSystem for tests and dry runs.
+// File locks only work on the local disk, so such a store isn't shared with other processes.
+func WithFileSystem(fileSystem fs.FileSystem) Option {
+	return func(s *simpleStore) {
+		s.fileSystem = fileSystem
+		s.onLocalFS = false
+	}
+}
+
+// WithSharedLocking makes a store created by NewSimpleStore coordinate through file locks with
+// the other processes using its root path. They wait for each other's extraction of a key instead
+// of extracting it again, and don't clean up or evict the items referenced by the others.
+// The locks are kept in the root path, in a .lock file and a .locks directory.
+// Stores opened by OpenSimpleStore share their index, they always coordinate.
+func WithSharedLocking() Option {
+	return func(s *simpleStore) {
+		s.shared = true
+	}
+}
+
+// WithLogger sets the logger recording the extraction and removal of items, the store is silent by default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *simpleStore) {
+		s.logger = logger
+	}
+}
+
+// WithMetrics sets the recorder counting the cache hits and misses, the extraction durations,
+// the evictions and the size of the store. No metrics are recorded by default.
+func WithMetrics(recorder metrics.Recorder) Option {
+	return func(s *simpleStore) {
+		s.metrics = recorder
+	}
+}
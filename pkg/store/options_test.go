@@ -0,0 +1,150 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"errors"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/bundle"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/metrics"
+	bundleprometheus "github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/metrics/prometheus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// room for two items extracted by the fileExtractor
+const twoItemsSize = int64(2 * len(testFileContent))
+
+func TestWithMaxSize_Put_WhenOverBudget_ShouldEvictLeastRecentlyUsedItem(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	bundleStore := NewSimpleStore(rootPath, WithMaxSize(twoItemsSize))
+	bundleStore.Put(sha256First, &fileExtractor{})
+	bundleStore.Put(sha256Second, &fileExtractor{})
+	bundleStore.Release(sha256First)
+	bundleStore.Release(sha256Second)
+
+	// using the first item again makes the second one the least recently used
+	bundleStore.Put(sha256First, &fileExtractor{})
+	bundleStore.Release(sha256First)
+
+	_, putErr := bundleStore.Put(sha256Third, &fileExtractor{})
+
+	assert.Nil(t, putErr)
+	assert.True(t, bundleStore.Exists(sha256First))
+	assert.False(t, bundleStore.Exists(sha256Second))
+	assert.True(t, bundleStore.Exists(sha256Third))
+	_, statErr := os.Stat(filepath.Join(rootPath, sha256Second))
+	assert.True(t, os.IsNotExist(statErr))
+}
+
+func TestWithMaxSize_Put_WhenReferencedItemsExceedBudget_ShouldReturnError(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	bundleStore := NewSimpleStore(rootPath, WithMaxSize(twoItemsSize))
+	bundleStore.Put(sha256First, &fileExtractor{})
+	bundleStore.Put(sha256Second, &fileExtractor{})
+
+	putPath, putErr := bundleStore.Put(sha256Third, &fileExtractor{})
+
+	assert.Equal(t, "", putPath)
+	sizeErr, ok := putErr.(*MaxSizeExceededError)
+	assert.True(t, ok)
+	assert.Equal(t, sha256Third, sizeErr.Key)
+	assert.Equal(t, int64(len(testFileContent)), sizeErr.Size)
+	assert.Equal(t, twoItemsSize, sizeErr.ReferencedSize)
+	assert.Equal(t, twoItemsSize, sizeErr.MaxSize)
+	assert.True(t, errors.Is(putErr, bundle.ErrOutOfSpace))
+
+	// items in use are kept, and nothing is left of the new item
+	assert.True(t, bundleStore.Exists(sha256First))
+	assert.True(t, bundleStore.Exists(sha256Second))
+	assert.False(t, bundleStore.Exists(sha256Third))
+	_, statErr := os.Stat(filepath.Join(rootPath, sha256Third))
+	assert.True(t, os.IsNotExist(statErr))
+	stagingItems, _ := filepath.Glob(filepath.Join(rootPath, stagingDirectoryName, "*"))
+	assert.Empty(t, stagingItems)
+}
+
+func TestWithMaxSize_OpenSimpleStore_ShouldPersistEvictions(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	bundleStore, _ := OpenSimpleStore(rootPath, WithMaxSize(twoItemsSize))
+	bundleStore.Put(sha256First, &fileExtractor{})
+	bundleStore.Release(sha256First)
+	bundleStore.Put(sha256Second, &fileExtractor{})
+	bundleStore.Put(sha256Third, &fileExtractor{})
+
+	reopenedStore, _ := OpenSimpleStore(rootPath, WithMaxSize(twoItemsSize))
+
+	assert.False(t, reopenedStore.Exists(sha256First))
+	assert.True(t, reopenedStore.Exists(sha256Second))
+	assert.True(t, reopenedStore.Exists(sha256Third))
+}
+
+// exposition gathers the metrics of recorder in the Prometheus text exposition format
+func exposition(t *testing.T, recorder *bundleprometheus.Recorder) string {
+	registry := prometheus.NewRegistry()
+	assert.Nil(t, registry.Register(recorder))
+	families, gatherErr := registry.Gather()
+	assert.Nil(t, gatherErr)
+
+	var out strings.Builder
+	encoder := expfmt.NewEncoder(&out, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, family := range families {
+		assert.Nil(t, encoder.Encode(family))
+	}
+	return out.String()
+}
+
+func TestWithMetrics_Put_ShouldCountHitsMissesAndExtractions(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	recorder := bundleprometheus.NewRecorder()
+	bundleStore := NewSimpleStore(rootPath, WithMetrics(recorder))
+	bundleStore.Put(sha256First, &fileExtractor{})
+	bundleStore.Put(sha256First, &fileExtractor{})
+	bundleStore.Put(sha256Second, &fileExtractor{})
+
+	metricsText := exposition(t, recorder)
+	assert.Contains(t, metricsText, "\nstore_puts_total{result=\"hit\"} 1\n")
+	assert.Contains(t, metricsText, "\nstore_puts_total{result=\"miss\"} 2\n")
+	assert.Contains(t, metricsText, "\nstore_extraction_duration_seconds_count 2\n")
+	// the size of the items is only known to stores with an index or a maximum size
+	assert.NotContains(t, metricsText, metrics.StoreSizeBytes)
+}
+
+func TestWithMetrics_Cleanup_ShouldCountEvictionsAndSize(t *testing.T) {
+	t.Parallel()
+	rootPath := createTempStoreRoot(t)
+	defer os.RemoveAll(rootPath)
+
+	recorder := bundleprometheus.NewRecorder()
+	bundleStore := NewSimpleStore(rootPath, WithMaxSize(twoItemsSize), WithMetrics(recorder))
+	bundleStore.Put(sha256First, &fileExtractor{})
+	bundleStore.Put(sha256Second, &fileExtractor{})
+	bundleStore.Release(sha256First)
+	bundleStore.Release(sha256Second)
+	bundleStore.Put(sha256Third, &fileExtractor{})
+	bundleStore.Release(sha256Third)
+
+	bundleStore.Cleanup()
+
+	metricsText := exposition(t, recorder)
+	assert.Contains(t, metricsText, "\nstore_evictions_total{reason=\"max_size\"} 1\n")
+	assert.Contains(t, metricsText, "\nstore_evictions_total{reason=\"cleanup\"} 2\n")
+	assert.Contains(t, metricsText, "\nstore_size_bytes 0\n")
+}
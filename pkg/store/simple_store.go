@@ -10,23 +10,97 @@ package store
 //go:generate mockgen -destination=mock_file_info.go -package=store github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs FileInfo
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/bundle"
 	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/fs"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/logging"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/metrics"
+	"github.com/google/uuid"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"time"
+)
+
+const (
+	// Items are extracted into a staging directory under the root path and only
+	// moved to their final location once the extraction has succeeded
+	stagingDirectoryName = ".staging"
+
+	// Processes sharing a root path coordinate through file locks,
+	// one for the whole store and one per item key
+	storeLockFileName  = ".lock"
+	locksDirectoryName = ".locks"
+
+	storeDirectoryMode fs.FileMode = 0755
 )
 
 // NewSimpleStore returns a new bundle.Cache to provide
 // caching for a bundle provider rootpath is the root
 // directory you want the cache to use for storage
-func NewSimpleStore(rootPath string) bundle.Cache {
-	return &simpleStore{
+//
+// Staging directories left behind by interrupted extractions are removed.
+// Different keys are extracted concurrently, a Put of a key that is being extracted
+// waits for that extraction and reuses the item, or returns its error.
+// The store is used by a single process, see WithSharedLocking for processes sharing
+// the root path. Reference counts are only kept in memory, use OpenSimpleStore
+// to keep them across restarts.
+func NewSimpleStore(rootPath string, options ...Option) bundle.Cache {
+	store := &simpleStore{
+		rootPath:   rootPath,
+		storeItems: make(map[string]storeItem),
+		fileSystem: fs.NewLocalFS(),
+		onLocalFS:  true,
+	}
+	store.applyOptions(options)
+	store.sweepStagingDirectories()
+	return store
+}
+
+// OpenSimpleStore returns a new bundle.Cache like NewSimpleStore, which records
+// the reference count and metadata of its items in an index in rootPath.
+// Items of a previous store that used rootPath are reloaded from the index,
+// with the reference counts they had. References of processes which exited
+// without releasing them, after a crash for example, are dropped: an item
+// no open store holds a reference to is reloaded with a reference count of 0.
+//
+// Several processes can open a store on the same root path, they share the
+// index, so an item in use by one of them is not cleaned up by another.
+// GetPath, Exists and GetInUseItemKeys reflect the index as of the last
+// Put, Load, Release or Cleanup of this store.
+func OpenSimpleStore(rootPath string, options ...Option) (bundle.Cache, error) {
+	store := &simpleStore{
 		rootPath:   rootPath,
 		storeItems: make(map[string]storeItem),
 		fileSystem: fs.NewLocalFS(),
+		indexPath:  filepath.Join(rootPath, indexFileName),
+		onLocalFS:  true,
+		shared:     true,
+	}
+	store.applyOptions(options)
+
+	if mkdirErr := store.fileSystem.MkdirAll(rootPath, storeDirectoryMode); mkdirErr != nil {
+		return nil, mkdirErr
+	}
+	store.sweepStagingDirectories()
+
+	if reconcileErr := store.reconcileIndex(); reconcileErr != nil {
+		return nil, reconcileErr
+	}
+	return store, nil
+}
+
+// applyOptions configures the store, which can only be shared with other processes on the local disk
+func (s *simpleStore) applyOptions(options []Option) {
+	for _, option := range options {
+		option(s)
 	}
+	s.shared = s.shared && s.onLocalFS
 }
 
 func newSimpleStore(rootPath string, fileSystem fs.FileSystem) bundle.Cache {
@@ -38,12 +112,16 @@ func newSimpleStore(rootPath string, fileSystem fs.FileSystem) bundle.Cache {
 }
 
 // Store Item records a key that has been put into the store.
-// protected: boolean. Set to true when we first put into the storeItems. Able to set to false by API.
-// when cleaning up the storeItems, items with protected set to true will not be cleaned up.
+// refCount: the number of bundles using the item, items with a refCount above 0 are not cleaned up.
+// size, createdAt, lastAccessedAt and source are only tracked by stores with an index or a maximum size.
 type storeItem struct {
-	key        string
-	refCount   int
-	pathToItem string
+	key            string
+	refCount       int
+	pathToItem     string
+	size           int64
+	createdAt      time.Time
+	lastAccessedAt time.Time
+	source         string
 }
 
 type simpleStore struct {
@@ -51,6 +129,64 @@ type simpleStore struct {
 	storeItems map[string]storeItem
 	fileSystem fs.FileSystem
 	mutex      sync.Mutex
+
+	// path to the index persisting storeItems, empty if the store has no index
+	indexPath string
+
+	// whether other processes may use rootPath, in which case the store
+	// coordinates with them through file locks
+	shared bool
+
+	// whether fileSystem is the local disk, which file locks need
+	onLocalFS bool
+
+	// maximum total size of the items in bytes, 0 if the store is unbounded
+	maxSize int64
+
+	// the extractions in progress by key, guarded by mutex
+	extractions map[string]*inFlightExtraction
+
+	// a shared lock per reference of this store to an item, by key, guarded by mutex.
+	// Stores on the same root path only remove the items they can lock exclusively.
+	useLocks map[string][]*fileLock
+
+	// records the extraction and removal of items, nil to discard the records
+	logger *slog.Logger
+
+	// records the puts, extractions and evictions of items, nil to discard them
+	metrics metrics.Recorder
+}
+
+func (s *simpleStore) log() *slog.Logger {
+	return logging.OrDiscard(s.logger)
+}
+
+func (s *simpleStore) recorder() metrics.Recorder {
+	return metrics.OrDiscard(s.metrics)
+}
+
+// recordPut counts a Put of an item which was already in the store, or which had to be extracted
+func (s *simpleStore) recordPut(result string) {
+	s.recorder().AddCounter(metrics.StorePutsTotal, 1, metrics.Labels{metrics.LabelResult: result})
+}
+
+// recordEvictions counts the items removed from the store and records its new size, s.mutex must be held
+func (s *simpleStore) recordEvictions(evictions int, reason string) {
+	s.recorder().AddCounter(metrics.StoreEvictionsTotal, float64(evictions), metrics.Labels{metrics.LabelReason: reason})
+	s.recordSize()
+}
+
+// recordSize records the size of the items, which is only known when the store tracks their metadata.
+// s.mutex must be held.
+func (s *simpleStore) recordSize() {
+	if !s.tracksMetadata() {
+		return
+	}
+	var totalSize int64
+	for _, item := range s.storeItems {
+		totalSize += item.size
+	}
+	s.recorder().SetGauge(metrics.StoreSizeBytes, float64(totalSize), nil)
 }
 
 func (s *simpleStore) Load(keys []string) error {
@@ -58,10 +194,21 @@ func (s *simpleStore) Load(keys []string) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	unlockStore, lockErr := s.lockStore()
+	if lockErr != nil {
+		return lockErr
+	}
+	defer unlockStore()
+
+	var loadedKeys []string
 	for _, key := range keys {
 		if _, exists := s.storeItems[key]; exists {
 			continue
 		}
+		// the bookkeeping of the store is next to its items, it is skipped when the whole root path is loaded
+		if isReservedKey(key) {
+			continue
+		}
 
 		itemPath, err := s.getPathToItemAndExistCheck(key)
 
@@ -75,42 +222,266 @@ func (s *simpleStore) Load(keys []string) error {
 			refCount:   0,
 			pathToItem: itemPath,
 		}
+
+		if s.tracksMetadata() {
+			if metadataErr := s.readItemMetadata(&newItem); metadataErr != nil {
+				return metadataErr
+			}
+		}
+
 		s.storeItems[key] = newItem
+		loadedKeys = append(loadedKeys, key)
+	}
+
+	if len(loadedKeys) > 0 {
+		if saveErr := s.saveIndex(); saveErr != nil {
+			for _, key := range loadedKeys {
+				delete(s.storeItems, key)
+			}
+			return saveErr
+		}
 	}
 	return nil
 }
 
 func (s *simpleStore) Put(key string, extractor bundle.Extractor) (string, error) {
-	// ensure that Put is an atomic operation
+	return s.PutWithContext(context.Background(), key, extractor)
+}
+
+func (s *simpleStore) PutWithContext(ctx context.Context, key string, extractor bundle.Extractor) (string, error) {
+	if isReservedKey(key) {
+		return "", fmt.Errorf("key %s is reserved for the bookkeeping of the store", key)
+	}
+
 	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	for {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			s.mutex.Unlock()
+			return "", ctxErr
+		}
+
+		// wait for the Put extracting the same key, and reuse what it extracted
+		extraction, inFlight := s.extractions[key]
+		if !inFlight {
+			break
+		}
+		s.mutex.Unlock()
+		select {
+		case <-extraction.done:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		if extraction.err != nil && !isContextError(extraction.err) {
+			return "", extraction.err
+		}
+		// the item is reused, or extracted again if the extraction was cancelled or the item removed since
+		s.mutex.Lock()
+	}
 
 	// there already exists an item, don't extract
-	if item, exists := s.storeItems[key]; exists {
-		// increment the item's refcount
-		item.refCount++
-		s.storeItems[key] = item
-		return item.pathToItem, nil
+	if itemPath, reused, reuseErr := s.reuseItem(key); reused || reuseErr != nil {
+		s.mutex.Unlock()
+		if reused {
+			s.recordPut(metrics.ResultHit)
+		}
+		return itemPath, reuseErr
+	}
+
+	// other keys are put while this one is extracted, Puts of this key wait for it
+	extraction := &inFlightExtraction{done: make(chan struct{})}
+	if s.extractions == nil {
+		s.extractions = make(map[string]*inFlightExtraction)
 	}
+	s.extractions[key] = extraction
+	s.mutex.Unlock()
+
+	itemPath, extractErr := s.extractItem(ctx, key, extractor)
+
+	s.mutex.Lock()
+	delete(s.extractions, key)
+	extraction.err = extractErr
+	close(extraction.done)
+	s.mutex.Unlock()
+	return itemPath, extractErr
+}
+
+// inFlightExtraction is the extraction of an item by a Put, done is closed once it is over
+type inFlightExtraction struct {
+	done chan struct{}
+	err  error
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// extractItem extracts the item of key and adds it to the store, s.mutex is only held to access the storeItems
+func (s *simpleStore) extractItem(ctx context.Context, key string, extractor bundle.Extractor) (string, error) {
+	// wait for other processes extracting the same key, and keep them waiting while we extract it
+	unlockItem, lockErr := s.lockItem(ctx, key)
+	if lockErr != nil {
+		return "", lockErr
+	}
+	defer unlockItem()
+
+	// another process may have put the item while we were waiting
+	s.mutex.Lock()
+	itemPath, reused, reuseErr := s.reuseItem(key)
+	s.mutex.Unlock()
+	if reused || reuseErr != nil {
+		if reused {
+			s.recordPut(metrics.ResultHit)
+		}
+		return itemPath, reuseErr
+	}
+	s.recordPut(metrics.ResultMiss)
 
 	// figure location to extract the files to and make the dir
-	itemPath := s.getPathToItem(key)
+	itemPath = s.getPathToItem(key)
+	stagingPath := s.getStagingPathForItem(key)
 
 	// create a storeItem from this
+	now := time.Now()
 	newItem := storeItem{
-		key:        key,
-		refCount:   1,
-		pathToItem: itemPath,
+		key:            key,
+		refCount:       1,
+		pathToItem:     itemPath,
+		createdAt:      now,
+		lastAccessedAt: now,
+	}
+	if sourcedExtractor, ok := extractor.(bundle.SourcedExtractor); ok {
+		newItem.source = sourcedExtractor.Source()
 	}
 
-	// now try to extract to the destination path
-	extractErr := extractor.Extract(itemPath, s.fileSystem)
+	// now try to extract to the staging path
+	s.log().Debug("extracting item", logging.KeyItem, key, logging.KeyURL, newItem.source)
+	extractErr := extractWithContext(ctx, extractor, stagingPath, s.fileSystem)
 	if extractErr != nil {
+		// don't leave a partially extracted item behind
+		s.fileSystem.RemoveAll(stagingPath)
+		s.log().Warn("failed to extract item", logging.KeyItem, key, logging.KeyError, extractErr)
 		return "", extractErr
 	}
+	s.recorder().ObserveHistogram(metrics.StoreExtractionDurationSeconds, time.Since(now).Seconds(), nil)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.addItem(newItem, stagingPath)
+}
+
+// extractWithContext uses ExtractWithContext on ContextExtractors, other extractors can't be cancelled
+func extractWithContext(ctx context.Context, extractor bundle.Extractor, extractLocation string, fileSystem fs.FileSystem) error {
+	if contextExtractor, ok := extractor.(bundle.ContextExtractor); ok {
+		return contextExtractor.ExtractWithContext(ctx, extractLocation, fileSystem)
+	}
+	return extractor.Extract(extractLocation, fileSystem)
+}
+
+// reuseItem increments the refCount of key if the item is already in the store.
+// Returns the path to the item and whether it was reused.
+func (s *simpleStore) reuseItem(key string) (string, bool, error) {
+	unlockStore, lockErr := s.lockStore()
+	if lockErr != nil {
+		return "", false, lockErr
+	}
+	defer unlockStore()
+
+	item, exists := s.storeItems[key]
+	if !exists && s.shared {
+		// another process may have put the item without us knowing, items are
+		// renamed into place once complete so an existing item path can be used as is
+		itemPath, existErr := s.getPathToItemAndExistCheck(key)
+		if existErr != nil {
+			return "", false, nil
+		}
+		item = storeItem{
+			key:        key,
+			pathToItem: itemPath,
+		}
+		if s.tracksMetadata() {
+			if metadataErr := s.readItemMetadata(&item); metadataErr != nil {
+				return "", false, metadataErr
+			}
+		}
+	} else if !exists {
+		return "", false, nil
+	}
+
+	useLock, useErr := s.lockItemUse(key)
+	if useErr != nil {
+		return "", false, useErr
+	}
+	if exists && s.shared {
+		// another store may have removed the item while this one didn't reference it
+		if _, existErr := s.getPathToItemAndExistCheck(key); existErr != nil {
+			useLock.Unlock()
+			delete(s.storeItems, key)
+			return "", false, s.saveIndex()
+		}
+	}
+
+	// increment the item's refcount
+	item.refCount++
+	item.lastAccessedAt = time.Now()
+	if commitErr := s.commitItem(item); commitErr != nil {
+		useLock.Unlock()
+		return "", false, commitErr
+	}
+	s.holdItemUse(key, useLock)
+	s.log().Debug("reusing item", logging.KeyItem, key, "refCount", item.refCount)
+	return item.pathToItem, true, nil
+}
+
+// addItem moves an extracted item from its staging path into place and adds it to the storeItems
+func (s *simpleStore) addItem(newItem storeItem, stagingPath string) (string, error) {
+	unlockStore, lockErr := s.lockStore()
+	if lockErr != nil {
+		s.fileSystem.RemoveAll(stagingPath)
+		return "", lockErr
+	}
+	defer unlockStore()
+
+	itemPath := newItem.pathToItem
+
+	if s.tracksMetadata() {
+		size, sizeErr := dirSize(s.fileSystem, stagingPath)
+		if sizeErr != nil {
+			s.fileSystem.RemoveAll(stagingPath)
+			return "", sizeErr
+		}
+		newItem.size = size
+	}
+
+	if evictErr := s.makeRoomFor(newItem); evictErr != nil {
+		s.fileSystem.RemoveAll(stagingPath)
+		return "", evictErr
+	}
+
+	useLock, useErr := s.lockItemUse(newItem.key)
+	if useErr != nil {
+		s.fileSystem.RemoveAll(stagingPath)
+		return "", useErr
+	}
+
+	// anything already at the destination path is unknown to the store, replace it
+	s.fileSystem.RemoveAll(itemPath)
+
+	// move the complete item into place, so that the item path never holds a partial extraction
+	renameErr := s.fileSystem.Rename(stagingPath, itemPath)
+	if renameErr != nil {
+		useLock.Unlock()
+		s.fileSystem.RemoveAll(stagingPath)
+		return "", renameErr
+	}
 
 	// no error, let's add it to the storeItems
-	s.storeItems[key] = newItem
+	if commitErr := s.commitItem(newItem); commitErr != nil {
+		useLock.Unlock()
+		return "", commitErr
+	}
+	s.holdItemUse(newItem.key, useLock)
+	s.log().Info("added item", logging.KeyItem, newItem.key, logging.KeyBytes, newItem.size)
+	s.recordSize()
 	return itemPath, nil
 }
 
@@ -147,6 +518,12 @@ func (s *simpleStore) Release(key string) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	unlockStore, lockErr := s.lockStore()
+	if lockErr != nil {
+		return lockErr
+	}
+	defer unlockStore()
+
 	item, exists := s.storeItems[key]
 
 	if !exists {
@@ -156,7 +533,10 @@ func (s *simpleStore) Release(key string) error {
 	item.refCount--
 
 	// put the item back into the storeItems
-	s.storeItems[key] = item
+	if commitErr := s.commitItem(item); commitErr != nil {
+		return commitErr
+	}
+	s.releaseItemUse(key)
 	return nil
 }
 
@@ -165,6 +545,12 @@ func (s *simpleStore) Cleanup() {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	unlockStore, lockErr := s.lockStore()
+	if lockErr != nil {
+		return
+	}
+	defer unlockStore()
+
 	// iterate all keys in our map, and only delete unprotected
 	var unreferencedItems []storeItem
 	for _, item := range s.storeItems {
@@ -173,11 +559,32 @@ func (s *simpleStore) Cleanup() {
 		}
 	}
 
-	// now, delete the unprotected items
+	// items referenced by other stores on the same root path are kept
+	unreferencedItems, unlockItems := s.lockUnusedItems(unreferencedItems)
+	defer unlockItems()
+
+	if len(unreferencedItems) == 0 {
+		return
+	}
+
+	// forget about the items first, a crash while deleting them must not leave partial items in the index
 	for _, item := range unreferencedItems {
-		s.fileSystem.RemoveAll(item.pathToItem)
 		delete(s.storeItems, item.key)
 	}
+	if saveErr := s.saveIndex(); saveErr != nil {
+		for _, item := range unreferencedItems {
+			s.storeItems[item.key] = item
+		}
+		s.log().Warn("failed to save index, skipping cleanup", logging.KeyError, saveErr)
+		return
+	}
+
+	// now, delete the unprotected items
+	for _, item := range unreferencedItems {
+		s.log().Info("removing unreferenced item", logging.KeyItem, item.key, logging.KeyBytes, item.size)
+		s.removeItemDirectory(item)
+	}
+	s.recordEvictions(len(unreferencedItems), metrics.ReasonCleanup)
 }
 
 func (s *simpleStore) GetInUseItemKeys() []string {
@@ -199,7 +606,170 @@ func (s *simpleStore) getPathToItem(itemKey string) string {
 	return filepath.Join(s.rootPath, itemKey)
 }
 
+// every Put gets its own staging directory
+func (s *simpleStore) getStagingPathForItem(itemKey string) string {
+	return filepath.Join(s.rootPath, stagingDirectoryName, itemKey+"-"+uuid.New().String())
+}
+
+// getItemKeyFromStagingDirectory reverses getStagingPathForItem
+func getItemKeyFromStagingDirectory(stagingDirectory string) (string, bool) {
+	separatorIndex := len(stagingDirectory) - len(uuid.Nil.String()) - 1
+	if separatorIndex < 1 || stagingDirectory[separatorIndex] != '-' {
+		return "", false
+	}
+	if _, uuidErr := uuid.Parse(stagingDirectory[separatorIndex+1:]); uuidErr != nil {
+		return "", false
+	}
+	return stagingDirectory[:separatorIndex], true
+}
+
+// staging directories that exist when the store is created were left behind
+// by extractions that never finished, e.g. because the process was killed,
+// unless another process sharing the store is extracting into them right now.
+func (s *simpleStore) sweepStagingDirectories() {
+	stagingRoot := filepath.Join(s.rootPath, stagingDirectoryName)
+	infos, readErr := s.fileSystem.ReadDir(stagingRoot)
+	if readErr != nil {
+		return
+	}
+
+	for _, info := range infos {
+		stagingPath := filepath.Join(stagingRoot, info.Name())
+
+		key, isItem := getItemKeyFromStagingDirectory(info.Name())
+		if isItem && s.shared {
+			// extractions hold the lock of their item
+			itemLock, lockErr := tryLockFile(s.getLockPathForItem(key))
+			if lockErr != nil {
+				continue
+			}
+			s.fileSystem.RemoveAll(stagingPath)
+			itemLock.Unlock()
+			continue
+		}
+		s.fileSystem.RemoveAll(stagingPath)
+	}
+}
+
+// removeItemDirectory moves the item out of its item path before deleting it,
+// so that the item path never holds a partially deleted item
+func (s *simpleStore) removeItemDirectory(item storeItem) {
+	stagingPath := s.getStagingPathForItem(item.key)
+	if renameErr := s.fileSystem.Rename(item.pathToItem, stagingPath); renameErr != nil {
+		s.fileSystem.RemoveAll(item.pathToItem)
+		return
+	}
+	s.fileSystem.RemoveAll(stagingPath)
+}
+
+func (s *simpleStore) getLockPathForItem(itemKey string) string {
+	return filepath.Join(s.rootPath, locksDirectoryName, itemKey+".lock")
+}
+
+// lockStore takes the lock of the whole store, and reloads the storeItems
+// from the index, which other processes may have changed.
+// It does nothing for stores that aren't shared. Returns the function releasing the lock.
+func (s *simpleStore) lockStore() (func(), error) {
+	if !s.shared {
+		return func() {}, nil
+	}
+
+	storeLock, lockErr := lockFile(filepath.Join(s.rootPath, storeLockFileName))
+	if lockErr != nil {
+		return nil, lockErr
+	}
+
+	if refreshErr := s.refreshFromIndex(); refreshErr != nil {
+		storeLock.Unlock()
+		return nil, refreshErr
+	}
+	return func() { storeLock.Unlock() }, nil
+}
+
+// lockItem takes the lock of a single item, waiting for any other process holding it until ctx is done.
+// It does nothing for stores that aren't shared. Returns the function releasing the lock.
+func (s *simpleStore) lockItem(ctx context.Context, itemKey string) (func(), error) {
+	if !s.shared {
+		return func() {}, nil
+	}
+
+	itemLock, lockErr := lockFileWithContext(ctx, s.getLockPathForItem(itemKey))
+	if lockErr != nil {
+		return nil, lockErr
+	}
+	return func() { itemLock.Unlock() }, nil
+}
+
+func (s *simpleStore) getUseLockPathForItem(itemKey string) string {
+	return filepath.Join(s.rootPath, locksDirectoryName, itemKey+".use.lock")
+}
+
+// lockItemUse takes a shared lock on the item for a new reference, it is held until the reference is released.
+// It does nothing for stores that aren't shared.
+func (s *simpleStore) lockItemUse(itemKey string) (*fileLock, error) {
+	if !s.shared {
+		return nil, nil
+	}
+	return lockFileShared(s.getUseLockPathForItem(itemKey))
+}
+
+// holdItemUse keeps the lock of a reference to the item until releaseItemUse, s.mutex must be held
+func (s *simpleStore) holdItemUse(itemKey string, useLock *fileLock) {
+	if useLock == nil {
+		return
+	}
+	if s.useLocks == nil {
+		s.useLocks = make(map[string][]*fileLock)
+	}
+	s.useLocks[itemKey] = append(s.useLocks[itemKey], useLock)
+}
+
+// releaseItemUse unlocks the lock of a reference to the item, s.mutex must be held.
+// References loaded from the index have no lock.
+func (s *simpleStore) releaseItemUse(itemKey string) {
+	useLocks := s.useLocks[itemKey]
+	if len(useLocks) == 0 {
+		return
+	}
+	useLocks[len(useLocks)-1].Unlock()
+	if len(useLocks) == 1 {
+		delete(s.useLocks, itemKey)
+	} else {
+		s.useLocks[itemKey] = useLocks[:len(useLocks)-1]
+	}
+}
+
+// lockUnusedItems locks the items exclusively, leaving out the ones referenced by other stores on the
+// same root path. Returns the items that can be removed, and the function unlocking them once they are.
+func (s *simpleStore) lockUnusedItems(items []storeItem) ([]storeItem, func()) {
+	if !s.shared {
+		return items, func() {}
+	}
+
+	var unusedItems []storeItem
+	var itemLocks []*fileLock
+	for _, item := range items {
+		itemLock, lockErr := tryLockFile(s.getUseLockPathForItem(item.key))
+		if lockErr != nil {
+			if lockErr != errLocked {
+				s.log().Warn("failed to lock item", logging.KeyItem, item.key, logging.KeyError, lockErr)
+			}
+			continue
+		}
+		unusedItems = append(unusedItems, item)
+		itemLocks = append(itemLocks, itemLock)
+	}
+	return unusedItems, func() {
+		for _, itemLock := range itemLocks {
+			itemLock.Unlock()
+		}
+	}
+}
+
 func (s *simpleStore) getPathToItemAndExistCheck(itemKey string) (string, error) {
+	if isReservedKey(itemKey) {
+		return "", fmt.Errorf("key %s is reserved for the bookkeeping of the store", itemKey)
+	}
 
 	itemPath := filepath.Join(s.rootPath, itemKey)
 	if _, err := s.fileSystem.Stat(itemPath); os.IsNotExist(err) {
@@ -207,3 +777,200 @@ func (s *simpleStore) getPathToItemAndExistCheck(itemKey string) (string, error)
 	}
 	return itemPath, nil
 }
+
+// isReservedKey tells whether key is the name of the staging directory, the locks or the index of the store
+// rather than an item. They all start with a dot, so that a half-extracted item is never loaded.
+func isReservedKey(key string) bool {
+	return strings.HasPrefix(key, ".")
+}
+
+func (s *simpleStore) hasIndex() bool {
+	return s.indexPath != ""
+}
+
+// whether the size and access times of the items are needed
+func (s *simpleStore) tracksMetadata() bool {
+	return s.hasIndex() || s.maxSize > 0
+}
+
+// makeRoomFor evicts unreferenced items, least recently used first,
+// until newItem fits in the maximum size of the store.
+func (s *simpleStore) makeRoomFor(newItem storeItem) error {
+	if s.maxSize <= 0 {
+		return nil
+	}
+
+	var totalSize, referencedSize int64
+	var unreferencedItems []storeItem
+	for _, item := range s.storeItems {
+		totalSize += item.size
+		if item.refCount > 0 {
+			referencedSize += item.size
+		} else {
+			unreferencedItems = append(unreferencedItems, item)
+		}
+	}
+
+	// the items referenced by other stores on the same root path can't be evicted either
+	unusedItems, unlockItems := s.lockUnusedItems(unreferencedItems)
+	defer unlockItems()
+	if len(unusedItems) < len(unreferencedItems) {
+		referencedSize = totalSize
+		for _, item := range unusedItems {
+			referencedSize -= item.size
+		}
+		unreferencedItems = unusedItems
+	}
+
+	if totalSize+newItem.size <= s.maxSize {
+		return nil
+	}
+	if referencedSize+newItem.size > s.maxSize {
+		return &MaxSizeExceededError{
+			Key:            newItem.key,
+			Size:           newItem.size,
+			ReferencedSize: referencedSize,
+			MaxSize:        s.maxSize,
+		}
+	}
+
+	sort.Slice(unreferencedItems, func(i, j int) bool {
+		return unreferencedItems[i].lastAccessedAt.Before(unreferencedItems[j].lastAccessedAt)
+	})
+
+	var evictedItems []storeItem
+	for _, item := range unreferencedItems {
+		if totalSize+newItem.size <= s.maxSize {
+			break
+		}
+		totalSize -= item.size
+		delete(s.storeItems, item.key)
+		evictedItems = append(evictedItems, item)
+	}
+
+	// same as Cleanup, forget about the items before deleting them
+	if saveErr := s.saveIndex(); saveErr != nil {
+		for _, item := range evictedItems {
+			s.storeItems[item.key] = item
+		}
+		return saveErr
+	}
+	for _, item := range evictedItems {
+		s.log().Info("evicting item", logging.KeyItem, item.key, logging.KeyBytes, item.size)
+		s.removeItemDirectory(item)
+	}
+	s.recordEvictions(len(evictedItems), metrics.ReasonMaxSize)
+	return nil
+}
+
+// commitItem updates item in the storeItems and persists the change in the index.
+// When the index can't be written the storeItems are left unchanged.
+func (s *simpleStore) commitItem(item storeItem) error {
+	previousItem, existed := s.storeItems[item.key]
+	s.storeItems[item.key] = item
+
+	if saveErr := s.saveIndex(); saveErr != nil {
+		if existed {
+			s.storeItems[item.key] = previousItem
+		} else {
+			delete(s.storeItems, item.key)
+		}
+		return saveErr
+	}
+	return nil
+}
+
+// refreshFromIndex replaces the storeItems with the ones in the index, it does nothing for stores without an index
+func (s *simpleStore) refreshFromIndex() error {
+	if !s.hasIndex() {
+		return nil
+	}
+
+	index, readErr := readIndex(s.fileSystem, s.indexPath)
+	if readErr != nil {
+		return readErr
+	}
+
+	storeItems := make(map[string]storeItem)
+	for key, entry := range index.Items {
+		storeItems[key] = storeItem{
+			key:            key,
+			refCount:       entry.RefCount,
+			pathToItem:     s.getPathToItem(key),
+			size:           entry.Size,
+			createdAt:      entry.CreatedAt,
+			lastAccessedAt: entry.LastAccessedAt,
+			source:         entry.Source,
+		}
+	}
+	s.storeItems = storeItems
+	return nil
+}
+
+// reconcileIndex removes the items which no longer exist on disk from the index, and resets the
+// reference counts of the items no process holds a use lock of. The kernel releases the use locks
+// of a process when it exits, so those references were left behind by processes which didn't release them.
+func (s *simpleStore) reconcileIndex() error {
+	unlockStore, lockErr := s.lockStore()
+	if lockErr != nil {
+		return lockErr
+	}
+	defer unlockStore()
+
+	var referencedItems []storeItem
+	for key, item := range s.storeItems {
+		if _, existErr := s.getPathToItemAndExistCheck(key); existErr != nil {
+			delete(s.storeItems, key)
+		} else if item.refCount > 0 {
+			referencedItems = append(referencedItems, item)
+		}
+	}
+
+	// without use locks, the references held by running processes can't be told apart
+	if s.shared && fileLocksSupported {
+		unusedItems, unlockItems := s.lockUnusedItems(referencedItems)
+		defer unlockItems()
+		for _, item := range unusedItems {
+			s.log().Info("dropping references of exited processes", logging.KeyItem, item.key, "refCount", item.refCount)
+			item.refCount = 0
+			s.storeItems[item.key] = item
+		}
+	}
+	return s.saveIndex()
+}
+
+// saveIndex persists the storeItems, it does nothing for stores without an index
+func (s *simpleStore) saveIndex() error {
+	if !s.hasIndex() {
+		return nil
+	}
+
+	index := &storeIndex{Items: make(map[string]indexEntry)}
+	for key, item := range s.storeItems {
+		index.Items[key] = indexEntry{
+			RefCount:       item.refCount,
+			Size:           item.size,
+			CreatedAt:      item.createdAt,
+			LastAccessedAt: item.lastAccessedAt,
+			Source:         item.source,
+		}
+	}
+	return writeIndex(s.fileSystem, s.indexPath, index)
+}
+
+// readItemMetadata fills in the metadata of an item that was put into the store without being indexed
+func (s *simpleStore) readItemMetadata(item *storeItem) error {
+	info, statErr := s.fileSystem.Stat(item.pathToItem)
+	if statErr != nil {
+		return statErr
+	}
+	size, sizeErr := dirSize(s.fileSystem, item.pathToItem)
+	if sizeErr != nil {
+		return sizeErr
+	}
+
+	item.size = size
+	item.createdAt = info.ModTime()
+	item.lastAccessedAt = info.ModTime()
+	return nil
+}
@@ -8,8 +8,10 @@ import (
 	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/bundle"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -19,8 +21,27 @@ const (
 	sha256Second                    = "2"
 	sha256Third                     = "3"
 	expectedExtractLocationForFirst = "/rootPath/1"
+	expectedStagingPrefixForFirst   = "/rootPath/.staging/1-"
 )
 
+// Matcher that tests for a staging path of an item
+type ofStagingPath struct {
+	prefix string
+}
+
+func OfStagingPath(prefix string) gomock.Matcher {
+	return &ofStagingPath{prefix: prefix}
+}
+
+func (o *ofStagingPath) Matches(x interface{}) bool {
+	path, ok := x.(string)
+	return ok && strings.HasPrefix(path, o.prefix)
+}
+
+func (o *ofStagingPath) String() string {
+	return "staging path with prefix: " + o.prefix
+}
+
 func TestSimpleStore_Put_WithValidItem_ShouldPut(t *testing.T) {
 	t.Parallel()
 	ctrl := gomock.NewController(t)
@@ -30,7 +51,10 @@ func TestSimpleStore_Put_WithValidItem_ShouldPut(t *testing.T) {
 	mockFileSystem := NewMockFileSystem(ctrl)
 
 	// assert that this is called only once
-	mockExtractor.EXPECT().Extract(expectedExtractLocationForFirst, mockFileSystem).Return(nil).Times(1)
+	mockExtractor.EXPECT().Extract(OfStagingPath(expectedStagingPrefixForFirst), mockFileSystem).Return(nil).Times(1)
+	// and that the extracted item is moved into place
+	mockFileSystem.EXPECT().RemoveAll(expectedExtractLocationForFirst).Return(nil).Times(1)
+	mockFileSystem.EXPECT().Rename(OfStagingPath(expectedStagingPrefixForFirst), expectedExtractLocationForFirst).Return(nil).Times(1)
 
 	internalCache := make(map[string]storeItem)
 	bundleStore := simpleStore{
@@ -77,7 +101,9 @@ func TestSimpleStore_Put_WithExtractError_ShouldNotPut(t *testing.T) {
 	extractError := errors.New("Extraction Error")
 
 	// Return an extraction error
-	mockExtractor.EXPECT().Extract(expectedExtractLocationForFirst, mockFileSystem).Return(extractError).Times(1)
+	mockExtractor.EXPECT().Extract(OfStagingPath(expectedStagingPrefixForFirst), mockFileSystem).Return(extractError).Times(1)
+	// and expect the partially extracted item to be removed
+	mockFileSystem.EXPECT().RemoveAll(OfStagingPath(expectedStagingPrefixForFirst)).Return(nil).Times(1)
 
 	bundleStore := newSimpleStore(cacheRootPath, mockFileSystem)
 
@@ -92,6 +118,44 @@ func TestSimpleStore_Put_WithExtractError_ShouldNotPut(t *testing.T) {
 	assert.Equal(t, "", putPath)
 }
 
+func TestSimpleStore_Put_WithRenameError_ShouldNotPut(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockExtractor := NewMockExtractor(ctrl)
+	mockFileSystem := NewMockFileSystem(ctrl)
+
+	renameError := errors.New("Rename Error")
+
+	mockExtractor.EXPECT().Extract(OfStagingPath(expectedStagingPrefixForFirst), mockFileSystem).Return(nil).Times(1)
+	mockFileSystem.EXPECT().RemoveAll(expectedExtractLocationForFirst).Return(nil).Times(1)
+	mockFileSystem.EXPECT().Rename(OfStagingPath(expectedStagingPrefixForFirst), expectedExtractLocationForFirst).Return(renameError).Times(1)
+	mockFileSystem.EXPECT().RemoveAll(OfStagingPath(expectedStagingPrefixForFirst)).Return(nil).Times(1)
+
+	bundleStore := newSimpleStore(cacheRootPath, mockFileSystem)
+
+	putPath, putError := bundleStore.Put(sha256First, mockExtractor)
+
+	assert.Equal(t, renameError, putError)
+	assert.False(t, bundleStore.Exists(sha256First))
+	assert.Equal(t, "", putPath)
+}
+
+func TestNewSimpleStore_WithLeftoverStagingDirectory_ShouldRemoveIt(t *testing.T) {
+	t.Parallel()
+	rootPath, _ := ioutil.TempDir("", "store")
+	defer os.RemoveAll(rootPath)
+
+	leftoverPath := filepath.Join(rootPath, stagingDirectoryName, sha256First+"-leftover")
+	os.MkdirAll(leftoverPath, 0755)
+
+	NewSimpleStore(rootPath)
+
+	_, statErr := os.Stat(leftoverPath)
+	assert.True(t, os.IsNotExist(statErr))
+}
+
 func TestSimpleStore_Load_WhenKeyDoesNotExist_ShouldLoad(t *testing.T) {
 	t.Parallel()
 	ctrl := gomock.NewController(t)
@@ -162,6 +226,24 @@ func TestSimpleStore_Load_WhenKeyNotExistOnDisk_ShouldNotLoad(t *testing.T) {
 	assert.NotNil(t, loadError)
 }
 
+func TestSimpleStore_Load_WithStoreBookkeeping_ShouldOnlyLoadItems(t *testing.T) {
+	t.Parallel()
+	rootPath, _ := ioutil.TempDir("", "store")
+	defer os.RemoveAll(rootPath)
+	bundleStore := NewSimpleStore(rootPath)
+
+	// a half-extracted item, as listed by a caller loading every directory of the root path
+	os.MkdirAll(filepath.Join(rootPath, stagingDirectoryName, sha256Second+"-extracting"), 0755)
+	os.MkdirAll(filepath.Join(rootPath, sha256First), 0755)
+
+	assert.Nil(t, bundleStore.Load([]string{stagingDirectoryName, sha256First}))
+
+	assert.True(t, bundleStore.Exists(sha256First))
+	assert.False(t, bundleStore.Exists(stagingDirectoryName))
+	_, putErr := bundleStore.Put(stagingDirectoryName, nil)
+	assert.NotNil(t, putErr)
+}
+
 func TestSimpleStore_Get_WhenDoesNotExist_ShouldReturnEmptyString(t *testing.T) {
 	t.Parallel()
 	ctrl := gomock.NewController(t)
@@ -305,8 +387,11 @@ func TestSimpleStore_Cleanup_ShouldCleanupOnlyUnprotectedItems(t *testing.T) {
 
 	mockFileSystem := NewMockFileSystem(ctrl)
 
-	mockFileSystem.EXPECT().RemoveAll(item2.pathToItem)
-	mockFileSystem.EXPECT().RemoveAll(item3.pathToItem)
+	// items are moved to a staging path before being deleted
+	mockFileSystem.EXPECT().Rename(item2.pathToItem, OfStagingPath("/rootPath/.staging/2-")).Return(nil)
+	mockFileSystem.EXPECT().RemoveAll(OfStagingPath("/rootPath/.staging/2-"))
+	mockFileSystem.EXPECT().Rename(item3.pathToItem, OfStagingPath("/rootPath/.staging/3-")).Return(nil)
+	mockFileSystem.EXPECT().RemoveAll(OfStagingPath("/rootPath/.staging/3-"))
 
 	bundleStore := simpleStore{
 		rootPath:   cacheRootPath,
@@ -315,4 +400,7 @@ func TestSimpleStore_Cleanup_ShouldCleanupOnlyUnprotectedItems(t *testing.T) {
 	}
 
 	bundleStore.Cleanup()
+
+	assert.Equal(t, 1, len(internalCache))
+	assert.Contains(t, internalCache, sha256First)
 }
@@ -0,0 +1,69 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package stream
+
+import (
+	"context"
+	"io"
+)
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// NewContextReader returns a reader which fails with ctx.Err() once ctx is done,
+// reads in progress are not interrupted.
+func NewContextReader(ctx context.Context, r io.Reader) io.Reader {
+	return &contextReader{ctx: ctx, r: r}
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if ctxErr := r.ctx.Err(); ctxErr != nil {
+		return 0, ctxErr
+	}
+	return r.r.Read(p)
+}
+
+type contextReadSeeker struct {
+	io.ReadSeeker
+	ctx context.Context
+}
+
+// NewContextReadSeeker is the same as NewContextReader for an io.ReadSeeker, seeking is not affected by ctx.
+// When r is also an io.ReaderAt, so is the returned reader, and ReadAt fails the same way once ctx is done.
+// The returned reader is an io.Closer closing r, neither Close nor closing ctx is needed when r isn't one.
+func NewContextReadSeeker(ctx context.Context, r io.ReadSeeker) io.ReadSeeker {
+	if readerAt, ok := r.(io.ReaderAt); ok {
+		return &contextReadSeekerAt{contextReadSeeker: contextReadSeeker{ReadSeeker: r, ctx: ctx}, readerAt: readerAt}
+	}
+	return &contextReadSeeker{ReadSeeker: r, ctx: ctx}
+}
+
+func (r *contextReadSeeker) Read(p []byte) (int, error) {
+	if ctxErr := r.ctx.Err(); ctxErr != nil {
+		return 0, ctxErr
+	}
+	return r.ReadSeeker.Read(p)
+}
+
+// Close closes the wrapped reader if it is an io.Closer, so that wrapping a stream doesn't leak it
+func (r *contextReadSeeker) Close() error {
+	if closer, ok := r.ReadSeeker.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+type contextReadSeekerAt struct {
+	contextReadSeeker
+	readerAt io.ReaderAt
+}
+
+func (r *contextReadSeekerAt) ReadAt(p []byte, off int64) (int, error) {
+	if ctxErr := r.ctx.Err(); ctxErr != nil {
+		return 0, ctxErr
+	}
+	return r.readerAt.ReadAt(p, off)
+}
@@ -0,0 +1,86 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package stream
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestContextReadSeeker_Read_WhenCancelled_ShouldReturnContextError(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	reader := NewContextReadSeeker(ctx, strings.NewReader("contents"))
+
+	n, readErr := reader.Read(make([]byte, 4))
+	assert.Equal(t, 4, n)
+	assert.Nil(t, readErr)
+
+	cancel()
+	n, readErr = reader.Read(make([]byte, 4))
+	assert.Equal(t, 0, n)
+	assert.Equal(t, context.Canceled, readErr)
+
+	// seeking is still possible
+	offset, seekErr := reader.Seek(0, io.SeekStart)
+	assert.Equal(t, int64(0), offset)
+	assert.Nil(t, seekErr)
+}
+
+func TestContextReader_Read_WhenCancelled_ShouldReturnContextError(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, readErr := NewContextReader(ctx, strings.NewReader("contents")).Read(make([]byte, 4))
+
+	assert.Equal(t, context.Canceled, readErr)
+}
+
+func TestContextReadSeeker_ReadAt_ShouldKeepReaderAt(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	reader := NewContextReadSeeker(ctx, strings.NewReader("contents"))
+
+	readerAt, ok := reader.(io.ReaderAt)
+	assert.True(t, ok)
+	buffer := make([]byte, 4)
+	n, readErr := readerAt.ReadAt(buffer, 4)
+	assert.Equal(t, 4, n)
+	assert.Nil(t, readErr)
+	assert.Equal(t, "ents", string(buffer))
+
+	cancel()
+	_, readErr = readerAt.ReadAt(buffer, 0)
+	assert.Equal(t, context.Canceled, readErr)
+
+	// readers which can't read at an offset aren't given ReadAt
+	_, ok = NewContextReadSeeker(ctx, struct{ io.ReadSeeker }{strings.NewReader("contents")}).(io.ReaderAt)
+	assert.False(t, ok)
+}
+
+// closingReader records whether it was closed
+type closingReader struct {
+	*strings.Reader
+	closed bool
+}
+
+func (r *closingReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestContextReadSeeker_Close_ShouldCloseReader(t *testing.T) {
+	t.Parallel()
+	closer := &closingReader{Reader: strings.NewReader("contents")}
+	reader := NewContextReadSeeker(context.Background(), closer)
+
+	assert.Nil(t, reader.(io.Closer).Close())
+	assert.True(t, closer.closed)
+	// readers which aren't closers have nothing to close
+	assert.Nil(t, NewContextReadSeeker(context.Background(), strings.NewReader("contents")).(io.Closer).Close())
+}
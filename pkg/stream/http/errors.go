@@ -0,0 +1,51 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// ReadError represents an error while
+// attempting to read from an HTTP server
+type ReadError struct {
+	err error
+}
+
+func (e *ReadError) Error() string {
+	return e.err.Error()
+}
+
+func (e *ReadError) Unwrap() error {
+	return e.err
+}
+
+// StatusError is returned when an HTTP server
+// responds with an unexpected status code. It matches os.ErrNotExist
+// with errors.Is for missing resources, and os.ErrPermission for denied ones.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d for %s", e.StatusCode, e.URL)
+}
+
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case os.ErrNotExist:
+		return e.StatusCode == http.StatusNotFound || e.StatusCode == http.StatusGone
+	case os.ErrPermission:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// whether a request which failed with this status may succeed when retried
+func (e *StatusError) temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == 408 || e.StatusCode == 429
+}
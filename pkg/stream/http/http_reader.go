@@ -0,0 +1,282 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/logging"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type httpReaderConfig struct {
+	NumRetries int
+	RetryWait  time.Duration
+	// Logger records the retries of requests, nil to discard them
+	Logger *slog.Logger
+}
+
+func newHTTPReaderConfig() httpReaderConfig {
+	//Retry up to 20 seconds at 5 second intervals
+	return httpReaderConfig{
+		NumRetries: 4,
+		RetryWait:  time.Duration(5) * time.Second,
+	}
+}
+
+// Implements io.ReadSeeker
+type httpReader struct {
+	ctx           context.Context
+	config        httpReaderConfig
+	resp          *http.Response
+	client        *http.Client
+	url           string
+	offset        int64
+	ContentLength int64
+	// ETag of the content, or its Last-Modified date when the server doesn't send an ETag
+	ContentID    string
+	etag         string
+	lastModified string
+}
+
+func newHTTPReader(ctx context.Context, client *http.Client, url string, contentLength int64, etag string, lastModified string, config httpReaderConfig) *httpReader {
+	contentID := etag
+	if contentID == "" {
+		contentID = lastModified
+	}
+	return &httpReader{
+		ctx:           ctx,
+		client:        client,
+		url:           url,
+		offset:        0,
+		ContentLength: contentLength,
+		ContentID:     contentID,
+		etag:          etag,
+		lastModified:  lastModified,
+		config:        config,
+	}
+}
+
+// ctx cancels the requests and retries of the reader
+func newHTTPReaderWithConfig(ctx context.Context, client *http.Client, url string, config httpReaderConfig) (*httpReader, error) {
+	var resp *http.Response
+	var err error
+	for i := config.NumRetries; i >= 0; i-- {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		resp, err = head(ctx, client, url)
+		if !shouldRetry(err) {
+			break
+		}
+		logging.OrDiscard(config.Logger).Warn("retrying http head request",
+			logging.KeyURL, url,
+			logging.KeyAttempt, config.NumRetries-i+1,
+			logging.KeyError, err)
+		if sleepErr := sleepWithContext(ctx, config.RetryWait); sleepErr != nil {
+			return nil, sleepErr
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.ContentLength < 0 {
+		return nil, fmt.Errorf("Content-Length of %s is unknown", url)
+	}
+
+	return newHTTPReader(ctx, client, url, resp.ContentLength, resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"), config), nil
+}
+
+func head(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, &ReadError{err}
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+func sleepWithContext(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// whether err is a transient failure, network errors and 5xx, 408 and 429 responses are retried
+func shouldRetry(err error) bool {
+	switch e := err.(type) {
+	case *ReadError:
+		return true
+	case *StatusError:
+		return e.temporary()
+	}
+	return false
+}
+
+/*
+ * Read up to len(p) bytes by performing a Range request.
+ * This is useful for large files and/or spotty connections,
+ * where connection issues may occur when reading from the Body
+ */
+func (r *httpReader) Read(p []byte) (n int, err error) {
+	for i := r.config.NumRetries; i >= 0; i-- {
+		if ctxErr := r.ctx.Err(); ctxErr != nil {
+			return 0, ctxErr
+		}
+
+		n, err = r.read(p)
+
+		if n == 0 && shouldRetry(err) {
+			logging.OrDiscard(r.config.Logger).Warn("retrying http read",
+				logging.KeyURL, r.url,
+				"offset", r.offset,
+				logging.KeyAttempt, r.config.NumRetries-i+1,
+				logging.KeyError, err)
+			if sleepErr := sleepWithContext(r.ctx, r.config.RetryWait); sleepErr != nil {
+				return 0, sleepErr
+			}
+			continue
+		}
+
+		break
+	}
+
+	// the bytes read before a failure are valid, the next Read resumes from them
+	if n > 0 && shouldRetry(err) {
+		err = nil
+	}
+
+	return
+}
+
+func (r *httpReader) read(p []byte) (n int, err error) {
+	if r.offset >= r.ContentLength {
+		return 0, io.EOF
+	}
+
+	if r.resp == nil {
+		requestErr := r.makeNewRangeRequest()
+		if requestErr != nil {
+			return 0, requestErr
+		}
+	}
+
+	bytesRead, readErr := r.resp.Body.Read(p)
+	r.offset += int64(bytesRead)
+
+	if readErr != nil {
+		// Error reading the body, close the current connection
+		defer r.closeConnection()
+		if readErr == io.EOF {
+			if r.offset < r.ContentLength {
+				return bytesRead, &ReadError{io.ErrUnexpectedEOF}
+			}
+			return bytesRead, io.EOF
+		}
+		return bytesRead, &ReadError{readErr}
+	}
+	return bytesRead, nil
+}
+
+func (r *httpReader) makeNewRangeRequest() error {
+	req, err := http.NewRequest(http.MethodGet, r.url, nil)
+	if err != nil {
+		return err
+	}
+
+	// Always open a connection read from current position to end of file
+	req.Header.Set("Range", fmt.Sprintf("bytes=%v-%v", r.offset, r.ContentLength-1))
+	// Fail instead of reading a mix of two versions of the file when it changes.
+	// If-Match never matches weak ETags, the Last-Modified date is used for them instead.
+	if r.etag != "" && !isWeakETag(r.etag) {
+		req.Header.Set("If-Match", r.etag)
+	} else if r.lastModified != "" {
+		req.Header.Set("If-Unmodified-Since", r.lastModified)
+	}
+
+	resp, err := r.client.Do(req.WithContext(r.ctx))
+	if err != nil {
+		return &ReadError{err}
+	}
+
+	// a server ignoring the Range header can only be used from the start of the file
+	if resp.StatusCode != http.StatusPartialContent && !(resp.StatusCode == http.StatusOK && r.offset == 0) {
+		resp.Body.Close()
+		return &StatusError{URL: r.url, StatusCode: resp.StatusCode}
+	}
+
+	r.resp = resp
+	return nil
+}
+
+// weak ETags only tell that two versions of the file are equivalent, not byte for byte identical
+func isWeakETag(etag string) bool {
+	return strings.HasPrefix(etag, "W/")
+}
+
+func (r *httpReader) closeConnection() {
+	if r.resp != nil {
+		r.resp.Body.Close()
+		r.resp = nil
+	}
+}
+
+// Close closes the connection in use, the reader can still be used afterwards
+func (r *httpReader) Close() error {
+	r.closeConnection()
+	return nil
+}
+
+func (r *httpReader) Seek(offset int64, whence int) (newOffset int64, err error) {
+	oldPos := r.offset
+
+	switch whence {
+	default:
+		return 0, fmt.Errorf("Seek: invalid whence %v", whence)
+	case io.SeekStart:
+		newOffset = offset
+	case io.SeekCurrent:
+		newOffset = r.offset + offset
+	case io.SeekEnd:
+		newOffset = r.ContentLength + offset
+	}
+
+	if newOffset < 0 {
+		return 0, fmt.Errorf("Seek: negative position %v", newOffset)
+	}
+	if newOffset > r.ContentLength {
+		newOffset = r.ContentLength
+	}
+	r.offset = newOffset
+
+	//Close the connection when seeking
+	//Special case: Dont close if the position hasn't changed
+	if oldPos != r.offset {
+		r.closeConnection()
+	}
+
+	return r.offset, nil
+}
@@ -0,0 +1,200 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPReader_Seek_ShouldReadFromOffset(t *testing.T) {
+	t.Parallel()
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			ranges = append(ranges, r.Header.Get("Range"))
+		}
+		w.Header().Set("ETag", testEtag)
+		http.ServeContent(w, r, "bundle.tar", testModTime, bytes.NewReader([]byte(testContent)))
+	}))
+	defer server.Close()
+
+	reader, err := newHTTPReaderWithConfig(context.Background(), http.DefaultClient, server.URL, httpReaderConfig{})
+	assert.Nil(t, err)
+
+	offset, seekErr := reader.Seek(7, io.SeekStart)
+	assert.Nil(t, seekErr)
+	assert.Equal(t, int64(7), offset)
+	data, readErr := ioutil.ReadAll(reader)
+
+	assert.Nil(t, readErr)
+	assert.Equal(t, testContent[7:], string(data))
+	assert.Equal(t, []string{"bytes=7-14"}, ranges)
+}
+
+func TestHTTPReader_Close_ShouldResumeWithNewRequest(t *testing.T) {
+	t.Parallel()
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			ranges = append(ranges, r.Header.Get("Range"))
+		}
+		w.Header().Set("ETag", testEtag)
+		http.ServeContent(w, r, "bundle.tar", testModTime, bytes.NewReader([]byte(testContent)))
+	}))
+	defer server.Close()
+
+	reader, _ := newHTTPReaderWithConfig(context.Background(), http.DefaultClient, server.URL, httpReaderConfig{})
+	reader.Read(make([]byte, 4))
+	assert.NotNil(t, reader.resp)
+
+	assert.Nil(t, reader.Close())
+	assert.Nil(t, reader.resp)
+	data, readErr := ioutil.ReadAll(reader)
+
+	assert.Nil(t, readErr)
+	assert.Equal(t, testContent[4:], string(data))
+	assert.Equal(t, []string{"bytes=0-14", "bytes=4-14"}, ranges)
+}
+
+func TestHTTPReader_Seek_FromEnd_ShouldReadLastBytes(t *testing.T) {
+	t.Parallel()
+	server := newTestServer(testEtag)
+	defer server.Close()
+
+	reader, _ := newHTTPReaderWithConfig(context.Background(), http.DefaultClient, server.URL, httpReaderConfig{})
+
+	offset, seekErr := reader.Seek(-8, io.SeekEnd)
+	data, _ := ioutil.ReadAll(reader)
+
+	assert.Nil(t, seekErr)
+	assert.Equal(t, int64(len(testContent)-8), offset)
+	assert.Equal(t, "contents", string(data))
+}
+
+func TestHTTPReader_Seek_WithNegativePosition_ShouldReturnError(t *testing.T) {
+	t.Parallel()
+	reader := newHTTPReader(context.Background(), http.DefaultClient, "http://www.file.com", 10, testEtag, "", httpReaderConfig{})
+
+	_, seekErr := reader.Seek(-1, io.SeekStart)
+
+	assert.NotNil(t, seekErr)
+}
+
+func TestHTTPReader_Read_WhenServerUnavailable_ShouldRetry(t *testing.T) {
+	t.Parallel()
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("ETag", testEtag)
+		http.ServeContent(w, r, "bundle.tar", testModTime, bytes.NewReader([]byte(testContent)))
+	}))
+	defer server.Close()
+
+	reader, _ := newHTTPReaderWithConfig(context.Background(), http.DefaultClient, server.URL, httpReaderConfig{NumRetries: 1})
+	data, readErr := ioutil.ReadAll(reader)
+
+	assert.Nil(t, readErr)
+	assert.Equal(t, testContent, string(data))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestHTTPReader_Read_WhenServerUnavailable_ShouldLogRetry(t *testing.T) {
+	t.Parallel()
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("ETag", testEtag)
+		http.ServeContent(w, r, "bundle.tar", testModTime, bytes.NewReader([]byte(testContent)))
+	}))
+	defer server.Close()
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	reader, _ := newHTTPReaderWithConfig(context.Background(), http.DefaultClient, server.URL, httpReaderConfig{NumRetries: 1, Logger: logger})
+	ioutil.ReadAll(reader)
+
+	var record map[string]interface{}
+	assert.Nil(t, json.Unmarshal(logs.Bytes(), &record))
+	assert.Equal(t, "WARN", record["level"])
+	assert.Equal(t, server.URL, record[logging.KeyURL])
+	assert.Equal(t, float64(1), record[logging.KeyAttempt])
+}
+
+func TestHTTPReader_Read_WhenConnectionDrops_ShouldResumeFromOffset(t *testing.T) {
+	t.Parallel()
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", testEtag)
+		if r.Method == http.MethodGet {
+			ranges = append(ranges, r.Header.Get("Range"))
+			if len(ranges) == 1 {
+				// promise the whole file but only send part of it
+				w.Header().Set("Content-Length", "15")
+				w.Header().Set("Content-Range", "bytes 0-14/15")
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write([]byte(testContent[:6]))
+				return
+			}
+		}
+		http.ServeContent(w, r, "bundle.tar", testModTime, bytes.NewReader([]byte(testContent)))
+	}))
+	defer server.Close()
+
+	reader, _ := newHTTPReaderWithConfig(context.Background(), http.DefaultClient, server.URL, httpReaderConfig{NumRetries: 1})
+	data, readErr := ioutil.ReadAll(reader)
+
+	assert.Nil(t, readErr)
+	assert.Equal(t, testContent, string(data))
+	assert.Equal(t, []string{"bytes=0-14", "bytes=6-14"}, ranges)
+}
+
+func TestHTTPReader_Read_WhenContentChanged_ShouldReturnStatusError(t *testing.T) {
+	t.Parallel()
+	server := newTestServer(`"v2"`)
+	defer server.Close()
+
+	reader := newHTTPReader(context.Background(), http.DefaultClient, server.URL, int64(len(testContent)), testEtag, "", httpReaderConfig{NumRetries: 1})
+	_, readErr := ioutil.ReadAll(reader)
+
+	statusErr, ok := readErr.(*StatusError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusPreconditionFailed, statusErr.StatusCode)
+}
+
+func TestHTTPReader_Read_WhenCancelled_ShouldStopRetrying(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	reader := newHTTPReader(ctx, http.DefaultClient, server.URL, int64(len(testContent)), testEtag, "",
+		httpReaderConfig{NumRetries: 4, RetryWait: time.Minute})
+
+	start := time.Now()
+	_, readErr := reader.Read(make([]byte, 1))
+
+	assert.Equal(t, context.DeadlineExceeded, readErr)
+	assert.True(t, time.Since(start) < time.Minute)
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package http provides a streamer implementation for http:// and https:// URLs.
+//
+// The server must support Range requests. When used along with the s3 streamer,
+// the s3 streamer should be registered first so that it handles S3 https:// URLs.
+package http
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/stream"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+)
+
+type streamer struct {
+	client *http.Client
+	config httpReaderConfig
+}
+
+// Option configures a Streamer created by NewStreamer
+type Option func(*streamer)
+
+// WithLogger sets the logger recording the retries of the streams, they are silent by default
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *streamer) {
+		s.config.Logger = logger
+	}
+}
+
+// NewStreamer creates a new Streamer that can be used to stream from http:// and https:// URLs
+// client can be nil and http.DefaultClient will then be used
+func NewStreamer(client *http.Client, options ...Option) stream.Streamer {
+	s := newStreamer(client, newHTTPReaderConfig())
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+func newStreamer(client *http.Client, config httpReaderConfig) *streamer {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &streamer{client: client, config: config}
+}
+
+func (s *streamer) CanStream(url string) bool {
+	_, err := parseHTTPUrl(url)
+	return err == nil
+}
+
+func (s *streamer) CreateStream(url string) (io.ReadSeeker, int64, string, error) {
+	return s.CreateStreamWithContext(context.Background(), url)
+}
+
+func (s *streamer) CreateStreamWithContext(ctx context.Context, url string) (io.ReadSeeker, int64, string, error) {
+	if _, err := parseHTTPUrl(url); err != nil {
+		return nil, 0, "", err
+	}
+
+	httpReader, err := newHTTPReaderWithConfig(ctx, s.client, url, s.config)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	return httpReader, httpReader.ContentLength, httpReader.ContentID, nil
+}
+
+func parseHTTPUrl(httpURL string) (*url.URL, error) {
+	parsedURL, err := url.Parse(httpURL)
+	if err != nil {
+		return nil, err
+	}
+	if (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		return nil, fmt.Errorf("Url %v is not a valid http url", httpURL)
+	}
+	return parsedURL, nil
+}
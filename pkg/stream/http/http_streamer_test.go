@@ -0,0 +1,137 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+const (
+	testContent = "bundle contents"
+	testEtag    = `"v1"`
+)
+
+var testModTime = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// serves testContent with Range support, using etag as ETag if not empty
+func newTestServer(etag string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		http.ServeContent(w, r, "bundle.tar", testModTime, bytes.NewReader([]byte(testContent)))
+	}))
+}
+
+func newTestStreamer() *streamer {
+	return newStreamer(nil, httpReaderConfig{NumRetries: 2, RetryWait: 0})
+}
+
+func TestHTTPStreamer_WithHTTPUrl_CanStreamTrue(t *testing.T) {
+	t.Parallel()
+	streamer := NewStreamer(nil)
+	assert.True(t, streamer.CanStream("http://www.file.com/bundle.tar"))
+	assert.True(t, streamer.CanStream("https://www.file.com/bundle.tar"))
+}
+
+func TestHTTPStreamer_WithOtherUrl_CanStreamFalse(t *testing.T) {
+	t.Parallel()
+	streamer := NewStreamer(nil)
+	assert.False(t, streamer.CanStream("s3://test/stream"))
+	assert.False(t, streamer.CanStream("/path/to/bundle.tar"))
+	assert.False(t, streamer.CanStream("file:///path/to/bundle.tar"))
+	assert.False(t, streamer.CanStream("http://"))
+}
+
+func TestHTTPStreamer_CreateStream_ShouldUseEtagAsContentID(t *testing.T) {
+	t.Parallel()
+	server := newTestServer(testEtag)
+	defer server.Close()
+
+	reader, length, contentID, err := newTestStreamer().CreateStream(server.URL)
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(testContent)), length)
+	assert.Equal(t, testEtag, contentID)
+	data, readErr := ioutil.ReadAll(reader)
+	assert.Nil(t, readErr)
+	assert.Equal(t, testContent, string(data))
+}
+
+func TestHTTPStreamer_CreateStream_WithoutEtag_ShouldUseLastModifiedAsContentID(t *testing.T) {
+	t.Parallel()
+	server := newTestServer("")
+	defer server.Close()
+
+	reader, _, contentID, err := newTestStreamer().CreateStream(server.URL)
+
+	assert.Nil(t, err)
+	assert.Equal(t, testModTime.Format(http.TimeFormat), contentID)
+	data, _ := ioutil.ReadAll(reader)
+	assert.Equal(t, testContent, string(data))
+}
+
+func TestHTTPStreamer_CreateStream_WithWeakEtag_ShouldReadRanges(t *testing.T) {
+	t.Parallel()
+	weakEtag := "W/" + testEtag
+	server := newTestServer(weakEtag)
+	defer server.Close()
+
+	reader, _, contentID, err := newTestStreamer().CreateStream(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, weakEtag, contentID)
+
+	// a weak ETag never matches If-Match, the ranged request would fail with 412 Precondition Failed
+	_, seekErr := reader.Seek(7, io.SeekStart)
+	assert.Nil(t, seekErr)
+	data, readErr := ioutil.ReadAll(reader)
+	assert.Nil(t, readErr)
+	assert.Equal(t, testContent[7:], string(data))
+}
+
+func TestHTTPStreamer_CreateStream_WhenNotFound_ShouldReturnStatusError(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	reader, _, _, err := newTestStreamer().CreateStream(server.URL)
+
+	assert.Nil(t, reader)
+	statusErr, ok := err.(*StatusError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.False(t, errors.Is(err, os.ErrPermission))
+}
+
+func TestHTTPStreamer_CreateStream_WithInvalidUrl_ShouldReturnError(t *testing.T) {
+	t.Parallel()
+	reader, _, _, err := newTestStreamer().CreateStream("s3://test/stream")
+
+	assert.Nil(t, reader)
+	assert.NotNil(t, err)
+}
+
+func TestHTTPStreamer_CreateStreamWithContext_WhenCancelled_ShouldReturnContextError(t *testing.T) {
+	t.Parallel()
+	server := newTestServer(testEtag)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	reader, _, _, err := newTestStreamer().CreateStreamWithContext(ctx, server.URL)
+
+	assert.Nil(t, reader)
+	assert.Equal(t, context.Canceled, err)
+}
@@ -3,6 +3,7 @@
 package local
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
@@ -24,6 +25,12 @@ func NewStreamer() stream.Streamer {
 	return &streamer{fs.NewLocalFS()}
 }
 
+// NewStreamerWithFileSystem creates a new stream.streamer like NewStreamer,
+// which streams files from fileSystem instead of the local file system.
+func NewStreamerWithFileSystem(fileSystem fs.FileSystem) stream.Streamer {
+	return newStreamer(fileSystem)
+}
+
 func newStreamer(fileSystem fs.FileSystem) *streamer {
 	return &streamer{fileSystem}
 }
@@ -34,11 +41,23 @@ func (s *streamer) CanStream(url string) bool {
 }
 
 func (s *streamer) CreateStream(url string) (io.ReadSeeker, int64, string, error) {
+	return s.createStream(context.Background(), url)
+}
+
+func (s *streamer) CreateStreamWithContext(ctx context.Context, url string) (io.ReadSeeker, int64, string, error) {
+	file, contentLength, md5Sum, err := s.createStream(ctx, url)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	return stream.NewContextReadSeeker(ctx, file), contentLength, md5Sum, nil
+}
+
+func (s *streamer) createStream(ctx context.Context, url string) (io.ReadSeeker, int64, string, error) {
 	filePath, err := parseURL(url)
 	if err != nil {
 		return nil, 0, "", err
 	}
-	md5Sum, md5Err := md5SumFile(filePath, s.fileSystem)
+	md5Sum, md5Err := md5SumFile(ctx, filePath, s.fileSystem)
 	if md5Err != nil {
 		return nil, 0, "", md5Err
 	}
@@ -71,7 +90,7 @@ func parseURL(url string) (string, error) {
 	return "", fmt.Errorf("url: %v is not a valid file system url", url)
 }
 
-func md5SumFile(filePath string, fileSystem fs.FileSystem) (string, error) {
+func md5SumFile(ctx context.Context, filePath string, fileSystem fs.FileSystem) (string, error) {
 	file, openErr := fileSystem.Open(filePath)
 	var hashString string
 	if openErr != nil {
@@ -81,7 +100,7 @@ func md5SumFile(filePath string, fileSystem fs.FileSystem) (string, error) {
 
 	hash := md5.New()
 
-	if _, err := io.Copy(hash, file); err != nil {
+	if _, err := io.Copy(hash, stream.NewContextReader(ctx, file)); err != nil {
 		return hashString, err
 	}
 	hashInBytes := hash.Sum(nil)[:16]
@@ -1,5 +1,13 @@
 package s3
 
+import (
+	"net/http"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
 // ReadError represents an error while
 // attempting to read from AWS S3
 type ReadError struct {
@@ -9,3 +17,92 @@ type ReadError struct {
 func (e *ReadError) Error() string {
 	return e.err.Error()
 }
+
+func (e *ReadError) Unwrap() error {
+	return e.err
+}
+
+// RequestError is returned when a request to AWS S3 fails. It is an awserr.Error, and an
+// awserr.RequestFailure when AWS S3 responded, so the errors of the requests keep their type.
+// It matches os.ErrNotExist with errors.Is when the bucket or object doesn't exist,
+// and os.ErrPermission when access to them is denied.
+type RequestError struct {
+	err awserr.Error
+}
+
+func (e *RequestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.err
+}
+
+// Code is the AWS error code of the request, see awserr.Error
+func (e *RequestError) Code() string {
+	return e.err.Code()
+}
+
+// Message is the AWS error message of the request, see awserr.Error
+func (e *RequestError) Message() string {
+	return e.err.Message()
+}
+
+// OrigErr is the error the AWS error of the request wraps, see awserr.Error
+func (e *RequestError) OrigErr() error {
+	return e.err.OrigErr()
+}
+
+func (e *RequestError) Is(target error) bool {
+	statusCode := 0
+	if requestFailure, ok := e.err.(awserr.RequestFailure); ok {
+		statusCode = requestFailure.StatusCode()
+	}
+
+	switch target {
+	case os.ErrNotExist:
+		switch e.err.Code() {
+		case s3.ErrCodeNoSuchKey, s3.ErrCodeNoSuchBucket, "NotFound":
+			return true
+		}
+		return statusCode == http.StatusNotFound
+	case os.ErrPermission:
+		switch e.err.Code() {
+		case "AccessDenied", "Forbidden", "InvalidAccessKeyId", "SignatureDoesNotMatch", "ExpiredToken":
+			return true
+		}
+		return statusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// requestFailureError is the RequestError of a request AWS S3 responded to, it is an awserr.RequestFailure
+type requestFailureError struct {
+	*RequestError
+	failure awserr.RequestFailure
+}
+
+func (e *requestFailureError) Unwrap() error {
+	return e.RequestError
+}
+
+// StatusCode is the HTTP status code of the response, see awserr.RequestFailure
+func (e *requestFailureError) StatusCode() int {
+	return e.failure.StatusCode()
+}
+
+// RequestID is the ID of the request, see awserr.RequestFailure
+func (e *requestFailureError) RequestID() string {
+	return e.failure.RequestID()
+}
+
+// newRequestError wraps the errors of AWS requests into RequestErrors, others are returned as is
+func newRequestError(err error) error {
+	if failure, ok := err.(awserr.RequestFailure); ok {
+		return &requestFailureError{RequestError: &RequestError{err: failure}, failure: failure}
+	}
+	if awsErr, ok := err.(awserr.Error); ok {
+		return &RequestError{err: awsErr}
+	}
+	return err
+}
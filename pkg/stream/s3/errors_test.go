@@ -0,0 +1,60 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestError_Is_ShouldMatchNotExistAndPermission(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		err        error
+		notExist   bool
+		permission bool
+	}{
+		{awserr.New("NoSuchKey", "The specified key does not exist.", nil), true, false},
+		{awserr.NewRequestFailure(awserr.New("NotFound", "Not Found", nil), 404, "request"), true, false},
+		{awserr.NewRequestFailure(awserr.New("Forbidden", "Forbidden", nil), 403, "request"), false, true},
+		{awserr.New("AccessDenied", "Access Denied", nil), false, true},
+		{awserr.New("RequestError", "send request failed", nil), false, false},
+	}
+
+	for _, testCase := range testCases {
+		err := newRequestError(testCase.err)
+		var requestErr *RequestError
+		assert.True(t, errors.As(err, &requestErr))
+		assert.Equal(t, testCase.notExist, errors.Is(err, os.ErrNotExist), testCase.err.Error())
+		assert.Equal(t, testCase.permission, errors.Is(err, os.ErrPermission), testCase.err.Error())
+		assert.Equal(t, testCase.err, errors.Unwrap(requestErr))
+	}
+
+	// other errors are not wrapped
+	readErr := &ReadError{errors.New("connection reset")}
+	assert.Equal(t, readErr, newRequestError(readErr))
+}
+
+func TestRequestError_ShouldKeepTypeOfAWSError(t *testing.T) {
+	t.Parallel()
+	origErr := errors.New("connection reset")
+
+	awsErr, ok := newRequestError(awserr.New("RequestError", "send request failed", origErr)).(awserr.Error)
+	assert.True(t, ok)
+	assert.Equal(t, "RequestError", awsErr.Code())
+	assert.Equal(t, "send request failed", awsErr.Message())
+	assert.Equal(t, origErr, awsErr.OrigErr())
+	_, isFailure := awsErr.(awserr.RequestFailure)
+	assert.False(t, isFailure)
+
+	failure, ok := newRequestError(awserr.NewRequestFailure(awserr.New("NotFound", "Not Found", nil), 404, "request")).(awserr.RequestFailure)
+	assert.True(t, ok)
+	assert.Equal(t, "NotFound", failure.Code())
+	assert.Equal(t, 404, failure.StatusCode())
+	assert.Equal(t, "request", failure.RequestID())
+}
@@ -6,10 +6,14 @@ package s3
 //go:generate mockgen -destination=mock_s3.go -package=s3 github.com/aws/aws-sdk-go/service/s3/s3iface S3API
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"time"
 
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/logging"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/metrics"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/request"
@@ -21,6 +25,13 @@ type s3ReaderConfig struct {
 	NumRetries int
 	RetryWait  time.Duration
 	BufferSize int64
+	// Number of BufferSize chunks downloaded concurrently ahead of the offset,
+	// the object is read over a single request when it is 1 or less
+	ReadAheadConcurrency int
+	// Logger records the retries of reads, nil to discard them
+	Logger *slog.Logger
+	// Metrics counts the retries of reads and the bytes read, nil to discard them
+	Metrics metrics.Recorder
 }
 
 func newS3ReaderConfig() s3ReaderConfig {
@@ -34,6 +45,7 @@ func newS3ReaderConfig() s3ReaderConfig {
 
 //Implements io.ReadSeeker
 type s3Reader struct {
+	ctx           aws.Context
 	config        s3ReaderConfig
 	resp          *s3.GetObjectOutput
 	bucket        string
@@ -42,10 +54,27 @@ type s3Reader struct {
 	offset        int64
 	ContentLength int64
 	Etag          string
+
+	// chunks downloaded in read-ahead mode, in order, the first one holds the offset
+	chunks        []*chunk
+	chunksContext context.Context
+	// cancels the download of the chunks
+	cancelChunks context.CancelFunc
+}
+
+// chunk of the object downloaded ahead of the offset
+type chunk struct {
+	offset int64
+	size   int64
+	// closed when the download completes
+	done chan struct{}
+	data []byte
+	err  error
 }
 
 func newS3Reader(s3Api s3iface.S3API, bucket string, key string, contentLength int64, etag string, config s3ReaderConfig) *s3Reader {
 	return &s3Reader{
+		ctx:           aws.BackgroundContext(),
 		bucket:        bucket,
 		key:           key,
 		s3:            s3Api,
@@ -67,22 +96,49 @@ func newS3ReaderWithConfig(s3Api s3iface.S3API, bucket string, key string, confi
 	})
 
 	if err != nil {
-		return nil, err
+		return nil, newRequestError(err)
 	}
 
 	return newS3Reader(s3Api, bucket, key, *resp.ContentLength, *resp.ETag, config), nil
 }
 
+// newS3ReaderWithContext creates a reader whose requests and retries are cancelled when ctx is done
+func newS3ReaderWithContext(ctx aws.Context, s3Api s3iface.S3API, bucket string, key string, config s3ReaderConfig) (*s3Reader, error) {
+	resp, err := s3Api.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+
+	if err != nil {
+		return nil, newRequestError(err)
+	}
+
+	reader := newS3Reader(s3Api, bucket, key, *resp.ContentLength, *resp.ETag, config)
+	reader.ctx = ctx
+	return reader, nil
+}
+
 /*
  * Read up to len(p) bytes by peforming a Ranged Get request.
  * This is useful for large objects and/or spotty connections,
  * where connection issues may occur when reading from the Body
  */
 func (r *s3Reader) Read(p []byte) (n int, err error) {
+	recorder := metrics.OrDiscard(r.config.Metrics)
+	defer func() {
+		if n > 0 {
+			recorder.AddCounter(metrics.S3ReadBytesTotal, float64(n), nil)
+		}
+	}()
+
 	//Retry to handle dropped / spotty connections
 	//AWS SDK retry strategy will only handle failed API calls, but not failed reads on the underlying stream
 	//AWS SDK will also not retry on client errors, e.g. no network connection is present
 	for i := r.config.NumRetries; i >= 0; i-- {
+		if ctxErr := r.ctx.Err(); ctxErr != nil {
+			return 0, ctxErr
+		}
+
 		n, err = r.read(p)
 
 		//Retry on all request errors - worst case this adds 20 seconds to the deployment
@@ -98,18 +154,29 @@ func (r *s3Reader) Read(p []byte) (n int, err error) {
 		}
 
 		if shouldRetry {
-			fmt.Printf("Error in s3Reader.Read: (%v). Retrying...\n", err)
-			time.Sleep(r.config.RetryWait)
+			logging.OrDiscard(r.config.Logger).Warn("retrying s3 read",
+				logging.KeyURL, fmt.Sprintf("s3://%s/%s", r.bucket, r.key),
+				"offset", r.offset,
+				logging.KeyAttempt, r.config.NumRetries-i+1,
+				logging.KeyError, err)
+			recorder.AddCounter(metrics.S3ReadRetriesTotal, 1, nil)
+			if sleepErr := aws.SleepWithContext(r.ctx, r.config.RetryWait); sleepErr != nil {
+				return n, r.ctx.Err()
+			}
 			continue
 		}
 
 		break
 	}
 
-	return
+	return n, newRequestError(err)
 }
 
 func (r *s3Reader) read(p []byte) (n int, err error) {
+	if r.config.ReadAheadConcurrency > 1 {
+		return r.readAhead(p)
+	}
+
 	if r.resp == nil {
 		getObjectErr := r.makeNewS3Request()
 		if getObjectErr != nil {
@@ -137,7 +204,7 @@ func (r *s3Reader) read(p []byte) (n int, err error) {
 
 func (r *s3Reader) makeNewS3Request() (err error) {
 	resp, getObjectErr := r.s3.GetObjectWithContext(
-		aws.BackgroundContext(),
+		r.ctx,
 		&s3.GetObjectInput{
 			Bucket:  aws.String(r.bucket),
 			Key:     aws.String(r.key),
@@ -158,6 +225,109 @@ func (r *s3Reader) makeNewS3Request() (err error) {
 	return nil
 }
 
+/*
+ * Read up to len(p) bytes from the chunk holding the offset,
+ * while up to ReadAheadConcurrency chunks are downloaded by concurrent Ranged Get requests.
+ */
+func (r *s3Reader) readAhead(p []byte) (n int, err error) {
+	if r.offset >= r.ContentLength {
+		return 0, io.EOF
+	}
+
+	r.scheduleChunks()
+	current := r.chunks[0]
+	select {
+	case <-current.done:
+	case <-r.ctx.Done():
+		r.discardChunks()
+		return 0, r.ctx.Err()
+	}
+
+	if current.err != nil {
+		// start over from the offset on the next read
+		r.discardChunks()
+		return 0, current.err
+	}
+
+	n = copy(p, current.data[r.offset-current.offset:])
+	r.offset += int64(n)
+	if r.offset == current.offset+current.size {
+		r.chunks = r.chunks[1:]
+	}
+	return n, nil
+}
+
+// scheduleChunks starts downloading chunks after the last scheduled one,
+// until ReadAheadConcurrency chunks are queued or the end of the object is reached
+func (r *s3Reader) scheduleChunks() {
+	if r.cancelChunks == nil {
+		r.chunksContext, r.cancelChunks = context.WithCancel(r.ctx)
+	}
+
+	nextOffset := r.offset
+	if len(r.chunks) > 0 {
+		last := r.chunks[len(r.chunks)-1]
+		nextOffset = last.offset + last.size
+	}
+
+	for len(r.chunks) < r.config.ReadAheadConcurrency && nextOffset < r.ContentLength {
+		c := &chunk{
+			offset: nextOffset,
+			size:   min(r.config.BufferSize, r.ContentLength-nextOffset),
+			done:   make(chan struct{}),
+		}
+		go r.downloadChunk(r.chunksContext, c)
+		r.chunks = append(r.chunks, c)
+		nextOffset += c.size
+	}
+}
+
+func (r *s3Reader) downloadChunk(ctx context.Context, c *chunk) {
+	defer close(c.done)
+
+	resp, getObjectErr := r.s3.GetObjectWithContext(
+		ctx,
+		&s3.GetObjectInput{
+			Bucket:  aws.String(r.bucket),
+			Key:     aws.String(r.key),
+			IfMatch: aws.String(r.Etag),
+			Range:   aws.String(fmt.Sprintf("bytes=%v-%v", c.offset, c.offset+c.size-1)),
+		},
+		request.WithResponseReadTimeout(10*time.Second))
+
+	if getObjectErr != nil {
+		c.err = getObjectErr
+		return
+	}
+	defer resp.Body.Close()
+
+	data := make([]byte, c.size)
+	if _, readErr := io.ReadFull(resp.Body, data); readErr != nil {
+		c.err = &ReadError{readErr}
+		return
+	}
+	c.data = data
+}
+
+// dropChunksBefore forgets the chunks before the offset, all of them when the offset is not in a scheduled chunk
+func (r *s3Reader) dropChunksBefore(offset int64) {
+	for len(r.chunks) > 0 && r.chunks[0].offset+r.chunks[0].size <= offset {
+		r.chunks = r.chunks[1:]
+	}
+	if len(r.chunks) == 0 || r.chunks[0].offset > offset {
+		r.discardChunks()
+	}
+}
+
+// discardChunks cancels the download of the scheduled chunks
+func (r *s3Reader) discardChunks() {
+	if r.cancelChunks != nil {
+		r.cancelChunks()
+		r.cancelChunks = nil
+	}
+	r.chunks = nil
+}
+
 func (r *s3Reader) closeS3Socket() {
 	if r.resp != nil {
 		r.resp.Body.Close()
@@ -165,6 +335,13 @@ func (r *s3Reader) closeS3Socket() {
 	}
 }
 
+// Close stops the downloads in progress, the reader can still be used afterwards
+func (r *s3Reader) Close() error {
+	r.closeS3Socket()
+	r.discardChunks()
+	return nil
+}
+
 func (r *s3Reader) Seek(offset int64, whence int) (newOffset int64, err error) {
 	oldPos := r.offset
 
@@ -187,6 +364,7 @@ func (r *s3Reader) Seek(offset int64, whence int) (newOffset int64, err error) {
 	//Special case: Dont close if the position hasn't changed
 	if oldPos != r.offset {
 		r.closeS3Socket()
+		r.dropChunksBefore(r.offset)
 	}
 
 	return r.offset, nil
@@ -4,17 +4,23 @@
 package s3
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	bundleprometheus "github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/metrics/prometheus"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -74,6 +80,39 @@ func TestS3Reader_Read_ReadFails_Retries(t *testing.T) {
 	assert.Equal(t, message, string(content))
 }
 
+func TestS3Reader_Read_ReadFails_ShouldCountRetriesAndBytes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockS3Client := NewMockS3API(ctrl)
+	mockS3Client.EXPECT().HeadObject(gomock.Any()).Return(&s3.HeadObjectOutput{
+		ContentLength: aws.Int64(int64(len(testBodyContent))),
+		ETag:          aws.String(testEtag),
+	}, nil).Times(1)
+
+	mockS3Client.EXPECT().GetObjectWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+		&s3.GetObjectOutput{
+			Body: ioutil.NopCloser(&errReader{}),
+		}, nil).Times(2)
+
+	mockS3Client.EXPECT().GetObjectWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+		&s3.GetObjectOutput{
+			Body: ioutil.NopCloser(strings.NewReader(testBodyContent)),
+		}, nil).Times(1)
+
+	recorder := bundleprometheus.NewRecorder()
+	config := newS3ReaderConfig()
+	config.RetryWait = 1 * time.Nanosecond
+	config.Metrics = recorder
+	s3Reader, _ := newS3ReaderWithConfig(mockS3Client, testBucket, testKey, config)
+
+	_, err := s3Reader.Read(make([]byte, 5))
+	assert.Nil(t, err)
+
+	metricsText := exposition(t, recorder)
+	assert.Contains(t, metricsText, "\ns3_read_retries_total 2\n")
+	assert.Contains(t, metricsText, "\ns3_read_bytes_total 5\n")
+}
+
 func TestS3Reader_Read_ReadFails_ExhaustsAllRetries(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -192,3 +231,170 @@ func TestS3Reader_SeekNoop_DoesNotCloseSocket(t *testing.T) {
 	s3Reader.Seek(1, io.SeekStart)
 	assert.NotNil(t, s3Reader.resp)
 }
+
+func TestS3Reader_Close_S3SocketClose(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockS3Client := NewMockS3API(ctrl)
+	setupS3MockExpects(mockS3Client)
+	s3Reader, _ := newS3ReaderBucketAndKey(mockS3Client, testBucket, testKey)
+
+	//Read to fill the buffer
+	s3Reader.Read(make([]byte, 1))
+	assert.NotNil(t, s3Reader.resp)
+
+	assert.Nil(t, s3Reader.Close())
+	assert.Nil(t, s3Reader.resp)
+}
+
+// serves the range of testBodyContent requested by a GetObjectInput
+func getObjectRange(_ aws.Context, input *s3.GetObjectInput, _ ...request.Option) (*s3.GetObjectOutput, error) {
+	var start, end int
+	fmt.Sscanf(*input.Range, "bytes=%d-%d", &start, &end)
+	return &s3.GetObjectOutput{
+		Body: ioutil.NopCloser(strings.NewReader(testBodyContent[start : end+1])),
+	}, nil
+}
+
+func newReadAheadConfig() s3ReaderConfig {
+	config := newS3ReaderConfig()
+	config.RetryWait = 1 * time.Nanosecond
+	config.BufferSize = 4
+	config.ReadAheadConcurrency = 2
+	return config
+}
+
+func TestS3Reader_ReadAhead_ShouldReturnChunksInOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockS3Client := NewMockS3API(ctrl)
+	var ranges []string
+	var rangesMutex sync.Mutex
+	mockS3Client.EXPECT().GetObjectWithContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx aws.Context, input *s3.GetObjectInput, options ...request.Option) (*s3.GetObjectOutput, error) {
+			assert.Equal(t, testEtag, *input.IfMatch)
+			rangesMutex.Lock()
+			ranges = append(ranges, *input.Range)
+			rangesMutex.Unlock()
+			return getObjectRange(ctx, input, options...)
+		}).Times(3)
+
+	s3Reader := newS3Reader(mockS3Client, testBucket, testKey, int64(len(testBodyContent)), testEtag, newReadAheadConfig())
+	content, err := ioutil.ReadAll(s3Reader)
+
+	assert.Nil(t, err)
+	assert.Equal(t, testBodyContent, string(content))
+	assert.ElementsMatch(t, []string{"bytes=0-3", "bytes=4-7", "bytes=8-10"}, ranges)
+}
+
+func TestS3Reader_ReadAhead_SeekWithinChunks_ShouldReuseDownloadedChunks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockS3Client := NewMockS3API(ctrl)
+	mockS3Client.EXPECT().GetObjectWithContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(getObjectRange).Times(3)
+
+	s3Reader := newS3Reader(mockS3Client, testBucket, testKey, int64(len(testBodyContent)), testEtag, newReadAheadConfig())
+	s3Reader.Read(make([]byte, 1))
+	s3Reader.Seek(6, io.SeekStart)
+	content, err := ioutil.ReadAll(s3Reader)
+
+	assert.Nil(t, err)
+	assert.Equal(t, testBodyContent[6:], string(content))
+}
+
+func TestS3Reader_ReadAhead_SeekBackwards_ShouldDownloadFromNewOffset(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockS3Client := NewMockS3API(ctrl)
+	mockS3Client.EXPECT().GetObjectWithContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(getObjectRange).MinTimes(3)
+
+	s3Reader := newS3Reader(mockS3Client, testBucket, testKey, int64(len(testBodyContent)), testEtag, newReadAheadConfig())
+	s3Reader.Seek(8, io.SeekStart)
+	s3Reader.Read(make([]byte, 1))
+	s3Reader.Seek(1, io.SeekStart)
+	content, err := ioutil.ReadAll(s3Reader)
+
+	assert.Nil(t, err)
+	assert.Equal(t, testBodyContent[1:], string(content))
+}
+
+func TestS3Reader_ReadAhead_ReadFails_Retries(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockS3Client := NewMockS3API(ctrl)
+	gomock.InOrder(
+		mockS3Client.EXPECT().GetObjectWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+			&s3.GetObjectOutput{
+				Body: ioutil.NopCloser(&errReader{}),
+			}, nil).Times(1),
+		mockS3Client.EXPECT().GetObjectWithContext(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(getObjectRange).AnyTimes(),
+	)
+
+	s3Reader := newS3Reader(mockS3Client, testBucket, testKey, int64(len(testBodyContent)), testEtag, newReadAheadConfig())
+	content, err := ioutil.ReadAll(s3Reader)
+
+	assert.Nil(t, err)
+	assert.Equal(t, testBodyContent, string(content))
+}
+
+func TestS3Reader_ReadAhead_CallFails_ReturnsError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockS3Client := NewMockS3API(ctrl)
+	mockS3Client.EXPECT().GetObjectWithContext(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+		&s3.GetObjectOutput{}, fmt.Errorf("Call failed")).MinTimes(1)
+
+	s3Reader := newS3Reader(mockS3Client, testBucket, testKey, int64(len(testBodyContent)), testEtag, newReadAheadConfig())
+	_, err := s3Reader.Read(make([]byte, 1))
+
+	assert.NotNil(t, err)
+}
+
+func TestS3Reader_Read_WhenCancelled_ShouldStopRetrying(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockS3Client := NewMockS3API(ctrl)
+	ctx, cancel := context.WithCancel(context.Background())
+	mockS3Client.EXPECT().GetObjectWithContext(ctx, gomock.Any(), gomock.Any()).DoAndReturn(
+		func(aws.Context, *s3.GetObjectInput, ...request.Option) (*s3.GetObjectOutput, error) {
+			cancel()
+			return &s3.GetObjectOutput{Body: ioutil.NopCloser(&errReader{})}, nil
+		}).Times(1)
+
+	config := newS3ReaderConfig()
+	config.RetryWait = time.Minute
+	s3Reader := newS3Reader(mockS3Client, testBucket, testKey, int64(len(testBodyContent)), testEtag, config)
+	s3Reader.ctx = ctx
+
+	_, err := s3Reader.Read(make([]byte, 1))
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestS3Reader_ReadAhead_WhenCancelled_ShouldReturnContextError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockS3Client := NewMockS3API(ctrl)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s3Reader := newS3Reader(mockS3Client, testBucket, testKey, int64(len(testBodyContent)), testEtag, newReadAheadConfig())
+	s3Reader.ctx = ctx
+
+	_, err := s3Reader.Read(make([]byte, 1))
+	assert.Equal(t, context.Canceled, err)
+}
+
+// exposition gathers the metrics of recorder in the Prometheus text exposition format
+func exposition(t *testing.T, recorder *bundleprometheus.Recorder) string {
+	registry := prometheus.NewRegistry()
+	assert.Nil(t, registry.Register(recorder))
+	families, gatherErr := registry.Gather()
+	assert.Nil(t, gatherErr)
+
+	var out strings.Builder
+	encoder := expfmt.NewEncoder(&out, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, family := range families {
+		assert.Nil(t, encoder.Encode(family))
+	}
+	return out.String()
+}
@@ -2,13 +2,16 @@
 package s3
 
 import (
+	"context"
 	"fmt"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/metrics"
 	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/stream"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"io"
+	"log/slog"
 	"os"
 	"regexp"
 )
@@ -19,12 +22,45 @@ import (
 
 type streamer struct {
 	client s3iface.S3API
+	config s3ReaderConfig
+}
+
+// Option configures a Streamer created by NewStreamer
+type Option func(*streamer)
+
+// WithReadAheadConcurrency makes the streams download up to concurrency chunks
+// of the object ahead of the current offset, using concurrent ranged requests.
+// Each chunk is buffered in memory until it is read.
+// By default, or when concurrency is 1 or less, the object is read over a single request.
+func WithReadAheadConcurrency(concurrency int) Option {
+	return func(s *streamer) {
+		s.config.ReadAheadConcurrency = concurrency
+	}
+}
+
+// WithLogger sets the logger recording the retries of the streams, they are silent by default
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *streamer) {
+		s.config.Logger = logger
+	}
+}
+
+// WithMetrics sets the recorder counting the retries of the streams and the bytes they read,
+// no metrics are recorded by default
+func WithMetrics(recorder metrics.Recorder) Option {
+	return func(s *streamer) {
+		s.config.Metrics = recorder
+	}
 }
 
 // NewStreamer creates a new Streamer that can be used to stream from AWS S3 URLs
 // client can be nil and will then be created using the local environment
-func NewStreamer(client s3iface.S3API) stream.Streamer {
-	return &streamer{client}
+func NewStreamer(client s3iface.S3API, options ...Option) stream.Streamer {
+	s := &streamer{client: client, config: newS3ReaderConfig()}
+	for _, option := range options {
+		option(s)
+	}
+	return s
 }
 
 func (s *streamer) CanStream(url string) bool {
@@ -33,6 +69,18 @@ func (s *streamer) CanStream(url string) bool {
 }
 
 func (s *streamer) CreateStream(url string) (io.ReadSeeker, int64, string, error) {
+	return s.createStream(url, func(bucket string, key string) (*s3Reader, error) {
+		return newS3ReaderWithConfig(s.client, bucket, key, s.config)
+	})
+}
+
+func (s *streamer) CreateStreamWithContext(ctx context.Context, url string) (io.ReadSeeker, int64, string, error) {
+	return s.createStream(url, func(bucket string, key string) (*s3Reader, error) {
+		return newS3ReaderWithContext(ctx, s.client, bucket, key, s.config)
+	})
+}
+
+func (s *streamer) createStream(url string, newReader func(bucket string, key string) (*s3Reader, error)) (io.ReadSeeker, int64, string, error) {
 	region, bucket, key, err := parseS3Url(url)
 	if err != nil {
 		return nil, 0, "", err
@@ -46,7 +94,7 @@ func (s *streamer) CreateStream(url string) (io.ReadSeeker, int64, string, error
 		}
 	}
 
-	s3Reader, err := newS3ReaderBucketAndKey(s.client, bucket, key)
+	s3Reader, err := newReader(bucket, key)
 	if err != nil {
 		return nil, 0, "", err
 	}
@@ -1,7 +1,10 @@
 package s3
 
 import (
+	"context"
 	"fmt"
+	"github.com/aws-robotics/aws-robomaker-bundle-support-library/pkg/stream"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -118,3 +121,42 @@ func TestPathToStream_WithS3UrlWithoutClientAndWithoutRegion_ShouldReturnUnsuppo
 	assert.Equal(t, "", md5)
 	assert.NotNil(t, err)
 }
+
+func TestNewStreamer_WithReadAheadConcurrency_ShouldConfigureStreams(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var contentLength int64 = 12345
+	mockS3Client := NewMockS3API(ctrl)
+	mockS3Client.EXPECT().HeadObject(OfHeadObjectInput("test", "stream")).Return(&s3.HeadObjectOutput{
+		ETag:          aws.String(testEtag),
+		ContentLength: &contentLength,
+	}, nil)
+
+	streamer := NewStreamer(mockS3Client, WithReadAheadConcurrency(4))
+	stream, _, _, err := streamer.CreateStream("s3://test/stream")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 4, stream.(*s3Reader).config.ReadAheadConcurrency)
+}
+
+func TestPathToStream_CreateStreamWithContext_ShouldUseContext(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var contentLength int64 = 12345
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockS3Client := NewMockS3API(ctrl)
+	mockS3Client.EXPECT().HeadObjectWithContext(ctx, OfHeadObjectInput("test", "stream")).Return(&s3.HeadObjectOutput{
+		ETag:          aws.String(testEtag),
+		ContentLength: &contentLength,
+	}, nil)
+
+	reader, _, _, err := NewStreamer(mockS3Client).(stream.ContextStreamer).CreateStreamWithContext(ctx, "s3://test/stream")
+
+	assert.Nil(t, err)
+	assert.Equal(t, ctx, reader.(*s3Reader).ctx)
+}
@@ -0,0 +1,68 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package stream
+
+import (
+	"fmt"
+	"io"
+)
+
+type sectionReader struct {
+	r      io.ReadSeeker
+	offset int64
+	size   int64
+
+	// the position in the section, and the position of r or -1 when r may have been moved by someone else
+	position  int64
+	rPosition int64
+}
+
+// NewSectionReader returns a reader of the size bytes of r starting at offset.
+// Seeking the section is free, r is only seeked when the section is read somewhere r isn't at,
+// so sequential reads don't cost seeks of remote streams. r must not be used while the section is read.
+func NewSectionReader(r io.ReadSeeker, offset int64, size int64) io.ReadSeeker {
+	return &sectionReader{r: r, offset: offset, size: size, rPosition: -1}
+}
+
+func (s *sectionReader) Read(p []byte) (int, error) {
+	if s.position >= s.size {
+		return 0, io.EOF
+	}
+	if remaining := s.size - s.position; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	if s.rPosition != s.offset+s.position {
+		if _, seekErr := s.r.Seek(s.offset+s.position, io.SeekStart); seekErr != nil {
+			s.rPosition = -1
+			return 0, seekErr
+		}
+		s.rPosition = s.offset + s.position
+	}
+
+	n, err := s.r.Read(p)
+	s.position += int64(n)
+	s.rPosition += int64(n)
+	return n, err
+}
+
+func (s *sectionReader) Seek(offset int64, whence int) (int64, error) {
+	var position int64
+	switch whence {
+	case io.SeekStart:
+		position = offset
+	case io.SeekCurrent:
+		position = s.position + offset
+	case io.SeekEnd:
+		position = s.size + offset
+	default:
+		return 0, fmt.Errorf("Seek: invalid whence %v", whence)
+	}
+	if position < 0 {
+		return 0, fmt.Errorf("Seek: negative position %v", position)
+	}
+
+	s.position = position
+	return position, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2019 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package stream
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// countingSeeker counts the seeks of the wrapped stream
+type countingSeeker struct {
+	io.ReadSeeker
+	seeks int
+}
+
+func (s *countingSeeker) Seek(offset int64, whence int) (int64, error) {
+	s.seeks++
+	return s.ReadSeeker.Seek(offset, whence)
+}
+
+func TestSectionReader_Read_ShouldOnlyReadSection(t *testing.T) {
+	t.Parallel()
+	stream := &countingSeeker{ReadSeeker: strings.NewReader("headersectiontrailer")}
+	section := NewSectionReader(stream, 6, 7)
+
+	content, readErr := ioutil.ReadAll(section)
+	assert.Nil(t, readErr)
+	assert.Equal(t, "section", string(content))
+	// sequential reads only seek the stream once
+	assert.Equal(t, 1, stream.seeks)
+}
+
+func TestSectionReader_Seek_ShouldReadFromNewPosition(t *testing.T) {
+	t.Parallel()
+	section := NewSectionReader(strings.NewReader("headersectiontrailer"), 6, 7)
+
+	position, seekErr := section.Seek(-3, io.SeekEnd)
+	assert.Nil(t, seekErr)
+	assert.Equal(t, int64(4), position)
+	content, _ := ioutil.ReadAll(section)
+	assert.Equal(t, "ion", string(content))
+
+	position, seekErr = section.Seek(1, io.SeekStart)
+	assert.Nil(t, seekErr)
+	assert.Equal(t, int64(1), position)
+	buffer := make([]byte, 3)
+	io.ReadFull(section, buffer)
+	assert.Equal(t, "ect", string(buffer))
+
+	position, seekErr = section.Seek(-2, io.SeekCurrent)
+	assert.Nil(t, seekErr)
+	assert.Equal(t, int64(2), position)
+	io.ReadFull(section, buffer)
+	assert.Equal(t, "cti", string(buffer))
+}
+
+func TestSectionReader_Seek_WithNegativePosition_ShouldReturnError(t *testing.T) {
+	t.Parallel()
+	section := NewSectionReader(strings.NewReader("headersectiontrailer"), 6, 7)
+
+	_, seekErr := section.Seek(-1, io.SeekStart)
+	assert.NotNil(t, seekErr)
+	_, seekErr = section.Seek(0, 42)
+	assert.NotNil(t, seekErr)
+}
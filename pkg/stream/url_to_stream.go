@@ -8,6 +8,7 @@
 package stream
 
 import (
+	"context"
 	"fmt"
 	"io"
 )
@@ -27,6 +28,15 @@ type Streamer interface {
 	CreateStream(url string) (io.ReadSeeker, int64, string, error)
 }
 
+// ContextStreamer is a Streamer whose streams can be cancelled with a context.
+// When ctx is done, opening the stream and reading from it fail with ctx.Err().
+type ContextStreamer interface {
+	Streamer
+
+	// Same as CreateStream, ctx applies to the returned stream as well
+	CreateStreamWithContext(ctx context.Context, url string) (io.ReadSeeker, int64, string, error)
+}
+
 // URLToStream converts a URL into an io.ReadSeeker
 // returns:
 // the io.ReadSeeker
@@ -34,9 +44,25 @@ type Streamer interface {
 // checksum of the file pointed to by path
 // error if any
 func URLToStream(url string) (io.ReadSeeker, int64, string, error) {
+	return URLToStreamWithContext(context.Background(), url)
+}
+
+// URLToStreamWithContext is the same as URLToStream, but the stream is cancelled when ctx is done.
+// Streams of streamers which aren't ContextStreamers fail at the next read once ctx is done.
+func URLToStreamWithContext(ctx context.Context, url string) (io.ReadSeeker, int64, string, error) {
 	for i := 0; i < len(streamers); i++ {
 		if streamers[i].CanStream(url) {
-			return streamers[i].CreateStream(url)
+			if contextStreamer, ok := streamers[i].(ContextStreamer); ok {
+				return contextStreamer.CreateStreamWithContext(ctx, url)
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, 0, "", ctxErr
+			}
+			stream, contentLength, contentID, err := streamers[i].CreateStream(url)
+			if err != nil {
+				return nil, 0, "", err
+			}
+			return NewContextReadSeeker(ctx, stream), contentLength, contentID, nil
 		}
 	}
 	return nil, 0, "", fmt.Errorf("no supported Streamer was found for %s", url)
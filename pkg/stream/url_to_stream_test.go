@@ -4,8 +4,11 @@
 package stream
 
 import (
+	"context"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
 	"testing"
 )
 
@@ -21,3 +24,29 @@ func TestPathToStream_WithUnsupportedUrl_ShouldReturnUnsupportedError(t *testing
 	assert.Nil(t, stream)
 	assert.NotNil(t, err)
 }
+
+// streams strings for ctxtest:// URLs, it is not a ContextStreamer
+type stringStreamer struct{}
+
+func (s *stringStreamer) CanStream(url string) bool {
+	return strings.HasPrefix(url, "ctxtest://")
+}
+
+func (s *stringStreamer) CreateStream(url string) (io.ReadSeeker, int64, string, error) {
+	return strings.NewReader(url), int64(len(url)), "", nil
+}
+
+func TestURLToStreamWithContext_WithStreamer_ShouldCancelStream(t *testing.T) {
+	RegisterStreamer(&stringStreamer{})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	stream, _, _, err := URLToStreamWithContext(ctx, "ctxtest://contents")
+	assert.Nil(t, err)
+
+	cancel()
+	_, readErr := stream.Read(make([]byte, 1))
+	assert.Equal(t, context.Canceled, readErr)
+
+	_, _, _, err = URLToStreamWithContext(ctx, "ctxtest://contents")
+	assert.Equal(t, context.Canceled, err)
+}